@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gradient-engineer/manifest"
+)
+
+// ToolboxCache is an on-disk, content-addressed store of toolbox archives and
+// their manifests. Archives are kept under archives/<sha256>.tar.xz,
+// manifests and signatures under their own suffixes next to them, and an
+// index maps every source URL to the digest it last served together with the
+// HTTP validators needed to revalidate it.
+type ToolboxCache struct {
+	Dir      string       // Root cache directory
+	MaxBytes int64        // Upper bound on the total size of cached archives; 0 disables eviction
+	Offline  bool         // Only serve archives that are already cached
+	Client   *http.Client // HTTP client used for fetching and revalidation
+	Retries  int          // Download attempts before giving up
+}
+
+// CacheEntry describes a single cached URL.
+type CacheEntry struct {
+	URL          string    `json:"url"`
+	Digest       string    `json:"digest"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	LastUsed     time.Time `json:"last_used"`
+}
+
+type cacheIndex struct {
+	Entries map[string]*CacheEntry `json:"entries"` // keyed by URL
+}
+
+// DefaultCacheDir returns $XDG_CACHE_HOME/gradient-engineer, falling back to
+// the platform user cache directory when XDG_CACHE_HOME is not set.
+func DefaultCacheDir() (string, error) {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		var err error
+		base, err = os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to determine cache directory: %w", err)
+		}
+	}
+	return filepath.Join(base, "gradient-engineer"), nil
+}
+
+// NewToolboxCache creates a cache rooted at dir.
+func NewToolboxCache(dir string, maxBytes int64) *ToolboxCache {
+	return &ToolboxCache{
+		Dir:      dir,
+		MaxBytes: maxBytes,
+		Client:   http.DefaultClient,
+		Retries:  5,
+	}
+}
+
+func (c *ToolboxCache) archivesDir() string {
+	return filepath.Join(c.Dir, "archives")
+}
+
+func (c *ToolboxCache) indexPath() string {
+	return filepath.Join(c.Dir, "index.json")
+}
+
+// Path returns the location of the body with the given digest fetched from
+// url.
+func (c *ToolboxCache) Path(url, digest string) string {
+	return filepath.Join(c.archivesDir(), digest+cacheSuffix(url))
+}
+
+// cacheSuffix is the file extension under which the body of url is stored.
+func cacheSuffix(url string) string {
+	switch {
+	case strings.HasSuffix(url, manifest.Suffix+manifest.SignatureSuffix):
+		return manifest.Suffix + manifest.SignatureSuffix
+	case strings.HasSuffix(url, manifest.Suffix):
+		return manifest.Suffix
+	}
+	return ".tar.xz"
+}
+
+// Kind tells what e holds: "archive", "manifest" or "signature".
+func (e CacheEntry) Kind() string {
+	switch cacheSuffix(e.URL) {
+	case manifest.Suffix:
+		return "manifest"
+	case manifest.Suffix + manifest.SignatureSuffix:
+		return "signature"
+	}
+	return "archive"
+}
+
+// Fetch returns the path of a cached copy of url, downloading or revalidating
+// it first unless the cache is offline. A stale copy is served when the
+// remote cannot be reached or fails with a server error, which is preferable
+// to failing mid-incident. progress, if non-nil, is called periodically
+// while the body is received, and once with Stale set if a stale copy is
+// served.
+func (c *ToolboxCache) Fetch(url string, progress func(DownloadProgress)) (string, error) {
+	idx, err := c.loadIndex()
+	if err != nil {
+		return "", err
+	}
+
+	entry := idx.Entries[url]
+	if entry != nil {
+		if _, err := os.Stat(c.Path(url, entry.Digest)); err != nil {
+			entry = nil
+		}
+	}
+
+	if c.Offline {
+		if entry == nil {
+			return "", fmt.Errorf("%s is not cached and offline mode is enabled", url)
+		}
+		return c.use(idx, entry)
+	}
+
+	fetched, err := c.download(url, entry, progress)
+	if errors.Is(err, errNotModified) {
+		return c.use(idx, entry)
+	}
+	if err != nil {
+		if entry == nil || !unreachable(err) {
+			return "", err
+		}
+		if progress != nil {
+			progress(DownloadProgress{URL: url, Stale: err})
+		}
+		return c.use(idx, entry)
+	}
+
+	fetched.FetchedAt = time.Now()
+	old := idx.Entries[url]
+	idx.Entries[url] = fetched
+	if old != nil && !digestReferenced(idx, old.Digest) {
+		// The previous body was replaced and nothing else refers to it
+		os.Remove(c.Path(url, old.Digest))
+	}
+	return c.use(idx, fetched)
+}
+
+// use marks entry as recently used, enforces the size bound and persists the
+// index.
+func (c *ToolboxCache) use(idx *cacheIndex, entry *CacheEntry) (string, error) {
+	entry.LastUsed = time.Now()
+	if c.MaxBytes > 0 {
+		c.evict(idx, c.MaxBytes, entry.Digest)
+	}
+	if err := c.saveIndex(idx); err != nil {
+		return "", err
+	}
+	return c.Path(entry.URL, entry.Digest), nil
+}
+
+// evict drops least recently used entries until the archives referenced by
+// the index fit into maxBytes. The archive identified by keep is never
+// evicted.
+func (c *ToolboxCache) evict(idx *cacheIndex, maxBytes int64, keep string) (removed int, freed int64) {
+	entries := make([]*CacheEntry, 0, len(idx.Entries))
+	for _, e := range idx.Entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+
+	total := indexSize(idx)
+	for _, e := range entries {
+		if total <= maxBytes {
+			break
+		}
+		if e.Digest == keep {
+			continue
+		}
+		delete(idx.Entries, e.URL)
+		removed++
+		if !digestReferenced(idx, e.Digest) {
+			if err := os.Remove(c.Path(e.URL, e.Digest)); err == nil || errors.Is(err, os.ErrNotExist) {
+				total -= e.Size
+				freed += e.Size
+			}
+		}
+	}
+	return removed, freed
+}
+
+// List returns all cache entries, most recently used first.
+func (c *ToolboxCache) List() ([]CacheEntry, error) {
+	idx, err := c.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	var out []CacheEntry
+	for _, e := range idx.Entries {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].LastUsed.After(out[j].LastUsed)
+	})
+	return out, nil
+}
+
+// Prune evicts entries until the cache fits into maxBytes (0 disables
+// eviction, as for MaxBytes), or every entry if all is set, and deletes
+// archives and partial downloads that are no longer referenced by the index.
+func (c *ToolboxCache) Prune(maxBytes int64, all bool) (removed int, freed int64, err error) {
+	idx, err := c.loadIndex()
+	if err != nil {
+		return 0, 0, err
+	}
+	switch {
+	case all:
+		for url := range idx.Entries {
+			delete(idx.Entries, url)
+			removed++
+		}
+	case maxBytes > 0:
+		removed, freed = c.evict(idx, maxBytes, "")
+	}
+
+	files, err := os.ReadDir(c.archivesDir())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return removed, freed, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	referenced := make(map[string]bool)
+	for _, e := range idx.Entries {
+		referenced[filepath.Base(c.Path(e.URL, e.Digest))] = true
+	}
+	for _, f := range files {
+		if referenced[f.Name()] {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.archivesDir(), f.Name())); err == nil {
+			freed += info.Size()
+		}
+	}
+
+	return removed, freed, c.saveIndex(idx)
+}
+
+func (c *ToolboxCache) loadIndex() (*cacheIndex, error) {
+	idx := &cacheIndex{Entries: make(map[string]*CacheEntry)}
+	data, err := os.ReadFile(c.indexPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache index: %w", err)
+	}
+	if err := json.Unmarshal(data, idx); err != nil {
+		// A corrupt index only costs a re-download, so start afresh.
+		return &cacheIndex{Entries: make(map[string]*CacheEntry)}, nil
+	}
+	if idx.Entries == nil {
+		idx.Entries = make(map[string]*CacheEntry)
+	}
+	return idx, nil
+}
+
+func (c *ToolboxCache) saveIndex(idx *cacheIndex) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache index: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.Dir, "index_*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.indexPath()); err != nil {
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+	return nil
+}
+
+// indexSize sums the sizes of the distinct archives referenced by idx.
+func indexSize(idx *cacheIndex) int64 {
+	seen := make(map[string]bool)
+	var total int64
+	for _, e := range idx.Entries {
+		if seen[e.Digest] {
+			continue
+		}
+		seen[e.Digest] = true
+		total += e.Size
+	}
+	return total
+}
+
+func digestReferenced(idx *cacheIndex, digest string) bool {
+	for _, e := range idx.Entries {
+		if e.Digest == digest {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"testing"
+)
+
+func TestCacheFetch(t *testing.T) {
+	bodies := map[string]string{
+		"/tb.tar.xz":                   "archive v1",
+		"/tb.tar.xz.manifest.json":     "{}",
+		"/tb.tar.xz.manifest.json.sig": "sig",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := bodies[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		etag := fmt.Sprintf("%q", body)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	c := NewToolboxCache(t.TempDir(), 0)
+	c.Retries = 1
+	files := func() []string {
+		entries, _ := os.ReadDir(c.archivesDir())
+		var names []string
+		for _, e := range entries {
+			names = append(names, filepath.Ext(e.Name()))
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	for path := range bodies {
+		if _, err := c.Fetch(srv.URL+path, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := fmt.Sprint(files()); got != "[.json .sig .xz]" {
+		t.Errorf("cached files %s; want one archive, manifest and signature", got)
+	}
+	list, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]string{}
+	for _, e := range list {
+		kinds[e.URL[len(srv.URL):]] = e.Kind()
+	}
+	want := map[string]string{
+		"/tb.tar.xz":                   "archive",
+		"/tb.tar.xz.manifest.json":     "manifest",
+		"/tb.tar.xz.manifest.json.sig": "signature",
+	}
+	if fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Errorf("kinds = %v; want %v", kinds, want)
+	}
+
+	// A new body replaces the old archive on disk
+	old, _ := c.Fetch(srv.URL+"/tb.tar.xz", nil)
+	bodies["/tb.tar.xz"] = "archive v2"
+	path, err := c.Fetch(srv.URL+"/tb.tar.xz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "archive v2" {
+		t.Errorf("served %q; want the new body", b)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("replaced archive is still cached: %v", err)
+	}
+	if got := len(files()); got != 3 {
+		t.Errorf("%d files cached; want 3", got)
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		maxBytes int64
+		all      bool
+		want     int // Entries left
+	}{
+		{"no limit", 0, false, 2},
+		{"limit", 10, false, 1},
+		{"all", 0, true, 0},
+		{"all ignores the limit", 1 << 20, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewToolboxCache(t.TempDir(), 0)
+			c.Retries = 1
+			for _, path := range []string{"/a.tar.xz", "/b.tar.xz"} {
+				if _, err := c.Fetch(srv.URL+path, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, _, err := c.Prune(tt.maxBytes, tt.all); err != nil {
+				t.Fatal(err)
+			}
+			list, err := c.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != tt.want {
+				t.Errorf("%d entries left; want %d", len(list), tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &httpStatusError{code: 503}, true},
+		{"rate limited", &httpStatusError{code: http.StatusTooManyRequests}, true},
+		{"not found", &httpStatusError{code: 404}, false},
+		{"connection refused", &url.Error{Op: "Get", URL: "http://x", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, true},
+		{"connection reset", fmt.Errorf("failed to download file: %w", syscall.ECONNRESET), true},
+		{"truncated body", fmt.Errorf("failed to download file: %w", io.ErrUnexpectedEOF), true},
+		{"bad range", fmt.Errorf("%w: asked for byte 10", errBadRange), true},
+		{"disk full", fmt.Errorf("failed to download file: %w", &fs.PathError{Op: "write", Path: "x.part", Err: syscall.ENOSPC}), false},
+		{"cannot create file", fmt.Errorf("failed to create cache file: %w", os.ErrPermission), false},
+		{"cannot rename", fmt.Errorf("failed to store download in cache: %w", &os.LinkError{Op: "rename", Err: syscall.EXDEV}), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("%s: retryable = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDownloadChecksContentRange(t *testing.T) {
+	const body = "0123456789abcdef"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("Range") != "" {
+			// Answers a resume from the start of the body
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(body)-1, len(body)))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	c := NewToolboxCache(t.TempDir(), 0)
+	c.Retries = 2
+	if err := os.MkdirAll(c.archivesDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	url := srv.URL + "/tb.tar.xz"
+	key := sha256.Sum256([]byte(url))
+	partPath := filepath.Join(c.archivesDir(), hex.EncodeToString(key[:8])+".part")
+	if err := os.WriteFile(partPath, []byte(body[:4]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(partPath+".json", []byte(`{"etag":"\"v1\""}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The mismatched range is dropped and the retry fetches the whole body
+	path, err := c.Fetch(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != body {
+		t.Errorf("cached %q; want %q", b, body)
+	}
+}
+
+func TestCacheFetchStale(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		fmt.Fprint(w, "archive")
+	}))
+	c := NewToolboxCache(t.TempDir(), 0)
+	c.Retries = 1
+	url := srv.URL + "/tb.tar.xz"
+	if _, err := c.Fetch(url, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		status int
+		closed bool
+		stale  bool // Whether the cached copy is served
+	}{
+		{name: "server error", status: http.StatusBadGateway, stale: true},
+		{name: "not found", status: http.StatusNotFound},
+		{name: "forbidden", status: http.StatusForbidden},
+		{name: "unreachable", closed: true, stale: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status = tt.status
+			if tt.closed {
+				srv.Close()
+			}
+			var notices []string
+			_, err := c.Fetch(url, func(p DownloadProgress) {
+				if n := p.staleNotice(); n != "" {
+					notices = append(notices, n)
+				}
+			})
+			if tt.stale && (err != nil || len(notices) != 1) {
+				t.Errorf("Fetch = %v with notices %q; want the cached copy and one notice", err, notices)
+			}
+			if !tt.stale && (err == nil || len(notices) != 0) {
+				t.Errorf("Fetch = %v with notices %q; want an error", err, notices)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gradient-engineer/playbook"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds user settings read from config.yaml. Command line flags take
+// precedence over anything set here.
+type Config struct {
+	ToolboxRepos []string           `yaml:"toolbox_repos,omitempty"` // Repositories tried in order
+	CACert       string             `yaml:"ca_cert,omitempty"`       // Extra PEM bundle trusted for toolbox downloads
+	LLM          playbook.LLMConfig `yaml:"llm,omitempty"`           // Provider and model for the summary; playbooks may override it
+	Redact       playbook.Redaction `yaml:"redact,omitempty"`        // What to mask before output is sent to the LLM; playbooks may add to it
+}
+
+// DefaultConfigPath returns $XDG_CONFIG_HOME/gradient-engineer/config.yaml,
+// falling back to ~/.config when XDG_CONFIG_HOME is not set.
+func DefaultConfigPath() (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to determine config directory: %w", err)
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "gradient-engineer", "config.yaml"), nil
+}
+
+// LoadConfig reads the config file at path. A missing file yields an empty
+// config.
+func LoadConfig(path string) (*Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// repoBaseURL turns a repository location into the HTTP(S) or file:// base
+// URL archives are appended to. s3://bucket/prefix is mapped to an anonymous
+// request against AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL (path-style), or the
+// public AWS endpoint (virtual-hosted style) when neither is set.
+func repoBaseURL(repo string) (string, error) {
+	repo = strings.TrimSpace(repo)
+	if !strings.HasSuffix(repo, "/") {
+		repo += "/"
+	}
+	scheme, rest, ok := strings.Cut(repo, "://")
+	if !ok {
+		return "", fmt.Errorf("toolbox repository %q has no scheme", repo)
+	}
+	switch scheme {
+	case "http", "https", "file":
+		return repo, nil
+	case "s3":
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		if bucket == "" {
+			return "", fmt.Errorf("toolbox repository %q has no bucket", repo)
+		}
+		endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+		if endpoint == "" {
+			endpoint = os.Getenv("AWS_ENDPOINT_URL")
+		}
+		if endpoint == "" {
+			return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", bucket, prefix), nil
+		}
+		return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(endpoint, "/"), bucket, prefix), nil
+	default:
+		return "", fmt.Errorf("toolbox repository %q uses unsupported scheme %s", repo, scheme)
+	}
+}
+
+// newHTTPClient returns a client that honours HTTPS_PROXY/HTTP_PROXY/NO_PROXY
+// and additionally trusts the certificates in caFile, if given.
+func newHTTPClient(caFile string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gradient-engineer/manifest"
+)
+
+// DownloadProgress reports the state of an in-flight download.
+type DownloadProgress struct {
+	URL     string
+	Done    int64     // Bytes on disk so far, including resumed bytes
+	Total   int64     // Expected size, or -1 when the server does not say
+	Resumed int64     // Bytes already on disk when the current attempt started
+	Attempt int       // 1-based attempt number
+	Started time.Time // Start of the current attempt
+	Stale   error     // Why a cached copy is served instead; the counts are unset
+}
+
+// staleNotice describes a stale copy being served, or returns "" for an
+// ordinary progress update.
+func (p DownloadProgress) staleNotice() string {
+	if p.Stale == nil {
+		return ""
+	}
+	return fmt.Sprintf("using a cached copy of %s: %v", p.URL, p.Stale)
+}
+
+// errNotModified is returned by download when the cached copy is still
+// current.
+var errNotModified = errors.New("not modified")
+
+// errBadRange is returned when a resumed download does not continue where
+// the partial file ends.
+var errBadRange = errors.New("partial content does not start at the requested offset")
+
+// httpStatusError is an unexpected HTTP response status.
+type httpStatusError struct {
+	code   int
+	status string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("bad status: %s", e.status)
+}
+
+// partialMeta records the validators of a partially downloaded body so that a
+// later attempt can resume it with If-Range.
+type partialMeta struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// download fetches url into the cache, retrying transient failures with
+// exponential backoff and resuming from whatever an earlier attempt left on
+// disk. cached, when non-nil, is revalidated with conditional headers.
+func (c *ToolboxCache) download(url string, cached *CacheEntry, progress func(DownloadProgress)) (*CacheEntry, error) {
+	if err := os.MkdirAll(c.archivesDir(), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	key := sha256.Sum256([]byte(url))
+	partPath := filepath.Join(c.archivesDir(), hex.EncodeToString(key[:8])+".part")
+
+	attempts := c.Retries
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		entry, err := c.downloadOnce(url, partPath, cached, attempt, progress)
+		if err == nil || errors.Is(err, errNotModified) || !retryable(err) || attempt == attempts {
+			return entry, err
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 8*time.Second)
+	}
+}
+
+func (c *ToolboxCache) downloadOnce(url, partPath string, cached *CacheEntry, attempt int, progress func(DownloadProgress)) (*CacheEntry, error) {
+	var meta partialMeta
+	var offset int64
+	if st, err := os.Stat(partPath); err == nil {
+		offset = st.Size()
+		if data, err := os.ReadFile(partPath + ".json"); err == nil {
+			_ = json.Unmarshal(data, &meta)
+		}
+	}
+	// Resuming is only safe when the server can tell us the body is unchanged
+	if meta.ETag == "" && meta.LastModified == "" {
+		offset = 0
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if meta.ETag != "" {
+			req.Header.Set("If-Range", meta.ETag)
+		} else {
+			req.Header.Set("If-Range", meta.LastModified)
+		}
+	} else if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if cached == nil {
+			return nil, &httpStatusError{code: resp.StatusCode, status: resp.Status}
+		}
+		return nil, errNotModified
+	case http.StatusPartialContent:
+		if offset == 0 {
+			return nil, &httpStatusError{code: resp.StatusCode, status: resp.Status}
+		}
+		if start, ok := rangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			// Appending would corrupt the file; retry from scratch
+			os.Remove(partPath)
+			os.Remove(partPath + ".json")
+			return nil, fmt.Errorf("%w: asked for byte %d, got %q", errBadRange, offset, resp.Header.Get("Content-Range"))
+		}
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// Full body: start over and remember the validators for resuming
+		offset = 0
+		flags |= os.O_TRUNC
+		meta = partialMeta{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+		if data, err := json.Marshal(meta); err == nil {
+			_ = os.WriteFile(partPath+".json", data, 0o644)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file no longer matches the remote; retry from scratch
+		os.Remove(partPath)
+		os.Remove(partPath + ".json")
+		return nil, &httpStatusError{code: resp.StatusCode, status: resp.Status}
+	default:
+		return nil, &httpStatusError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	file, err := os.OpenFile(partPath, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache file: %w", err)
+	}
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	pw := &progressWriter{
+		w:        file,
+		progress: progress,
+		state: DownloadProgress{
+			URL:     url,
+			Done:    offset,
+			Total:   total,
+			Resumed: offset,
+			Attempt: attempt,
+			Started: time.Now(),
+		},
+	}
+	pw.report()
+	_, copyErr := io.Copy(pw, resp.Body)
+	closeErr := file.Close()
+	pw.report()
+	if copyErr != nil {
+		return nil, fmt.Errorf("failed to download file: %w", copyErr)
+	}
+	if closeErr != nil {
+		return nil, fmt.Errorf("failed to write cache file: %w", closeErr)
+	}
+	if total >= 0 && pw.state.Done != total {
+		return nil, fmt.Errorf("failed to download file: got %d of %d bytes: %w", pw.state.Done, total, io.ErrUnexpectedEOF)
+	}
+
+	digest, err := manifest.HashFile(partPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash download: %w", err)
+	}
+	if err := os.Rename(partPath, c.Path(url, digest)); err != nil {
+		return nil, fmt.Errorf("failed to store download in cache: %w", err)
+	}
+	os.Remove(partPath + ".json")
+
+	return &CacheEntry{
+		URL:          url,
+		Digest:       digest,
+		Size:         pw.state.Done,
+		ETag:         meta.ETag,
+		LastModified: meta.LastModified,
+	}, nil
+}
+
+// rangeStart returns the first byte of a "bytes first-last/size"
+// Content-Range header.
+func rangeStart(contentRange string) (int64, bool) {
+	rest, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(first, 10, 64)
+	return n, err == nil
+}
+
+// retryable reports whether a failed attempt is worth repeating. Local
+// failures such as a full disk are not.
+func retryable(err error) bool {
+	var se *httpStatusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusRequestTimeout ||
+			se.code == http.StatusTooManyRequests || se.code == http.StatusRequestedRangeNotSatisfiable
+	}
+	return errors.Is(err, errBadRange) || networkError(err)
+}
+
+// unreachable reports whether err means the server could not be reached or
+// failed, rather than answering that the file is gone or forbidden.
+func unreachable(err error) bool {
+	var se *httpStatusError
+	if errors.As(err, &se) {
+		return se.code >= 500
+	}
+	return networkError(err)
+}
+
+// networkError reports whether err came from talking to the server rather
+// than from the local filesystem. File errors are ruled out first because
+// the errno they wrap also satisfies net.Error.
+func networkError(err error) bool {
+	var pe *fs.PathError
+	var le *os.LinkError
+	if errors.As(err, &pe) || errors.As(err, &le) {
+		return false
+	}
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// progressWriter counts bytes written through it and reports progress at most
+// every 100ms.
+type progressWriter struct {
+	w        io.Writer
+	progress func(DownloadProgress)
+	state    DownloadProgress
+	last     time.Time
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.state.Done += int64(n)
+	if time.Since(p.last) >= 100*time.Millisecond {
+		p.report()
+	}
+	return n, err
+}
+
+func (p *progressWriter) report() {
+	if p.progress == nil {
+		return
+	}
+	p.last = time.Now()
+	p.progress(p.state)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+// errFileMismatch is returned when an extracted file does not match the
+// digest recorded in the archive manifest.
+var errFileMismatch = errors.New("file does not match manifest")
+
+// tarExtractor unpacks toolbox archives without letting any entry touch
+// anything outside of the destination directory.
+type tarExtractor struct {
+	root *os.Root
+
+	// mounts maps absolute symlink target prefixes to the directory inside
+	// the root they are bound to at run time (e.g. /nix -> toolbox/nix under
+	// proot). Absolute targets outside of these prefixes are rejected.
+	mounts map[string]string
+
+	// files holds the expected SHA-256 of every regular file, keyed by entry
+	// name. A nil map disables the check.
+	files map[string]string
+
+	links []string // symlinks created so far, re-checked once extraction is complete
+}
+
+// extractTarXz unpacks the xz-compressed tar stream r into dest.
+func extractTarXz(r io.Reader, dest string, mounts, files map[string]string) error {
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return fmt.Errorf("failed to open destination: %w", err)
+	}
+	defer root.Close()
+
+	xzReader, err := xz.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to create XZ reader: %w", err)
+	}
+
+	e := &tarExtractor{root: root, mounts: mounts, files: files}
+	return e.extract(tar.NewReader(xzReader))
+}
+
+func (e *tarExtractor) extract(tr *tar.Reader) error {
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+		if err := e.extractEntry(header, tr); err != nil {
+			return err
+		}
+	}
+
+	// Links are only judged lexically while extracting; now that every entry
+	// exists, follow each chain for real so that combinations such as
+	// "a -> ." plus "b -> a/.." are caught regardless of entry order.
+	for _, name := range e.links {
+		if err := e.resolve(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *tarExtractor) extractEntry(header *tar.Header, r io.Reader) error {
+	name, err := localName(header.Name)
+	if err != nil {
+		return err
+	}
+
+	// Ensure the parent directory exists
+	if dir := path.Dir(name); dir != "." {
+		if err := e.root.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		// We use 0755 to ensure we can write to the directory, regardless of original permissions
+		if err := e.root.MkdirAll(name, 0o755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", name, err)
+		}
+
+	case tar.TypeReg:
+		if err := e.removeExisting(name); err != nil {
+			return err
+		}
+		perm := os.FileMode(header.Mode) & 0o777
+		file, err := e.root.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+		if err != nil {
+			return fmt.Errorf("failed to create file %s: %w", name, err)
+		}
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(file, h), r); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to copy file content: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", name, err)
+		}
+		if e.files != nil {
+			if want, ok := e.files[header.Name]; !ok || want != hex.EncodeToString(h.Sum(nil)) {
+				return fmt.Errorf("%s: %w", header.Name, errFileMismatch)
+			}
+		}
+
+	case tar.TypeSymlink:
+		if err := e.checkSymlink(name, header.Linkname); err != nil {
+			return err
+		}
+		if err := e.removeExisting(name); err != nil {
+			return err
+		}
+		if err := e.root.Symlink(header.Linkname, name); err != nil {
+			return fmt.Errorf("failed to create symlink %s -> %s: %w", name, header.Linkname, err)
+		}
+		e.links = append(e.links, name)
+
+	case tar.TypeLink:
+		target, err := localName(header.Linkname)
+		if err != nil {
+			return fmt.Errorf("hardlink %s: %w", name, err)
+		}
+		if err := e.removeExisting(name); err != nil {
+			return err
+		}
+		if err := e.root.Link(target, name); err != nil {
+			return fmt.Errorf("failed to create hardlink %s -> %s: %w", name, target, err)
+		}
+
+	default:
+		return fmt.Errorf("unsupported file type: %c (%d) for %s", header.Typeflag, header.Typeflag, header.Name)
+	}
+	return nil
+}
+
+// removeExisting deletes a non-directory entry already present at name so
+// that a later entry can never write through an earlier symlink.
+func (e *tarExtractor) removeExisting(name string) error {
+	info, err := e.root.Lstat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", name, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("refusing to replace directory %s", name)
+	}
+	if err := e.root.Remove(name); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", name, err)
+	}
+	return nil
+}
+
+// checkSymlink rejects link targets that would resolve outside of the root.
+// Relative targets are resolved against the link's directory; absolute ones
+// must fall under one of the configured mounts.
+func (e *tarExtractor) checkSymlink(name, target string) error {
+	if target == "" {
+		return fmt.Errorf("symlink %s has an empty target", name)
+	}
+	if path.IsAbs(target) {
+		if _, ok := e.mapMount(target); !ok {
+			return fmt.Errorf("symlink %s -> %s points outside the toolbox", name, target)
+		}
+		return nil
+	}
+	if !filepath.IsLocal(filepath.FromSlash(path.Join(path.Dir(name), target))) {
+		return fmt.Errorf("symlink %s -> %s escapes the toolbox", name, target)
+	}
+	return nil
+}
+
+// resolve follows name component by component, expanding every symlink on
+// the way, and fails if the walk ever climbs above the root.
+func (e *tarExtractor) resolve(name string) error {
+	const maxHops = 40
+
+	var stack []string
+	pending := strings.Split(name, "/")
+	hops := 0
+	for len(pending) > 0 {
+		part := pending[0]
+		pending = pending[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(stack) == 0 {
+				return fmt.Errorf("symlink %s escapes the toolbox", name)
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		stack = append(stack, part)
+		current := strings.Join(stack, "/")
+		info, err := e.root.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // dangling links are harmless as long as they stay inside
+		}
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", current, err)
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			continue
+		}
+
+		hops++
+		if hops > maxHops {
+			return fmt.Errorf("symlink %s: too many levels of symbolic links", name)
+		}
+		target, err := e.root.Readlink(current)
+		if err != nil {
+			return fmt.Errorf("failed to read symlink %s: %w", current, err)
+		}
+		stack = stack[:len(stack)-1]
+		if path.IsAbs(target) {
+			mapped, ok := e.mapMount(target)
+			if !ok {
+				return fmt.Errorf("symlink %s -> %s points outside the toolbox", current, target)
+			}
+			stack = nil
+			target = mapped
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return nil
+}
+
+// mapMount translates an absolute link target into its location inside the
+// root, if it falls under one of the mounts.
+func (e *tarExtractor) mapMount(target string) (string, bool) {
+	clean := path.Clean(target)
+	for prefix, dir := range e.mounts {
+		if clean == prefix {
+			return dir, true
+		}
+		if strings.HasPrefix(clean, prefix+"/") {
+			return dir + clean[len(prefix):], true
+		}
+	}
+	return "", false
+}
+
+// localName validates an archive entry name and returns it in clean form.
+func localName(name string) (string, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(name) || !filepath.IsLocal(filepath.FromSlash(clean)) {
+		return "", fmt.Errorf("archive entry %q escapes the toolbox", name)
+	}
+	return clean, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// entry is one member of a crafted archive.
+type entry struct {
+	name string
+	typ  byte
+	link string // Target of symlinks and hardlinks
+	body string // Content of regular files
+}
+
+func dir(name string) entry           { return entry{name: name, typ: tar.TypeDir} }
+func file(name, body string) entry    { return entry{name: name, typ: tar.TypeReg, body: body} }
+func symlink(name, link string) entry { return entry{name: name, typ: tar.TypeSymlink, link: link} }
+func hardlink(name, link string) entry {
+	return entry{name: name, typ: tar.TypeLink, link: link}
+}
+
+// buildTar writes entries into an uncompressed tar archive in memory.
+func buildTar(t *testing.T, entries []entry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typ, Linkname: e.link, Mode: 0o644}
+		if e.typ == tar.TypeDir {
+			hdr.Mode = 0o755
+		}
+		if e.typ == tar.TypeReg {
+			hdr.Size = int64(len(e.body))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if e.typ == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func sha(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+		files   map[string]string // Manifest digests; nil skips the check
+		wantErr string            // Substring of the expected error; empty for success
+		errIs   error
+		check   func(t *testing.T, dest string)
+	}{
+		{
+			name:    "parent directory in name",
+			entries: []entry{file("../evil", "x")},
+			wantErr: "escapes the toolbox",
+		},
+		{
+			name:    "parent directory inside name",
+			entries: []entry{file("bin/../../evil", "x")},
+			wantErr: "escapes the toolbox",
+		},
+		{
+			name:    "absolute name",
+			entries: []entry{file("/etc/evil", "x")},
+			wantErr: "escapes the toolbox",
+		},
+		{
+			name:    "absolute symlink outside /nix",
+			entries: []entry{symlink("etc", "/etc")},
+			wantErr: "points outside the toolbox",
+		},
+		{
+			name:    "absolute symlink into /nix",
+			entries: []entry{dir("toolbox/nix/store/"), symlink("bin", "/nix/store")},
+		},
+		{
+			name:    "relative symlink climbing out",
+			entries: []entry{symlink("up", "../..")},
+			wantErr: "escapes the toolbox",
+		},
+		{
+			name: "symlink then file through it",
+			entries: []entry{
+				dir("real/"),
+				symlink("link", "real"),
+				file("link/f", "inside"),
+			},
+			check: func(t *testing.T, dest string) {
+				if b, err := os.ReadFile(filepath.Join(dest, "real", "f")); err != nil || string(b) != "inside" {
+					t.Errorf("real/f = %q, %v; want the file written through the link", b, err)
+				}
+			},
+		},
+		{
+			name: "symlink to /nix then file through it",
+			entries: []entry{
+				symlink("lib", "/nix/store"),
+				file("lib/evil", "x"),
+			},
+			// os.Root refuses to follow the absolute link at extraction time
+			wantErr: "path escapes",
+		},
+		{
+			name: "file replaces earlier symlink",
+			entries: []entry{
+				file("target", "original"),
+				symlink("link", "target"),
+				file("link", "replaced"),
+			},
+			check: func(t *testing.T, dest string) {
+				if b, _ := os.ReadFile(filepath.Join(dest, "target")); string(b) != "original" {
+					t.Errorf("target = %q; the later file was written through the symlink", b)
+				}
+				info, err := os.Lstat(filepath.Join(dest, "link"))
+				if err != nil || !info.Mode().IsRegular() {
+					t.Errorf("link is not a regular file: %v, %v", info, err)
+				}
+			},
+		},
+		{
+			name: "symlink chain climbing out",
+			entries: []entry{
+				symlink("a", "."),
+				symlink("b", "a/.."),
+			},
+			wantErr: "escapes the toolbox",
+		},
+		{
+			name: "symlink chain in reverse order",
+			entries: []entry{
+				symlink("b", "a/.."),
+				symlink("a", "."),
+			},
+			wantErr: "escapes the toolbox",
+		},
+		{
+			name: "hardlink escaping",
+			entries: []entry{
+				hardlink("passwd", "../../etc/passwd"),
+			},
+			wantErr: "escapes the toolbox",
+		},
+		{
+			name: "absolute hardlink",
+			entries: []entry{
+				hardlink("passwd", "/etc/passwd"),
+			},
+			wantErr: "escapes the toolbox",
+		},
+		{
+			name: "valid hardlink",
+			entries: []entry{
+				file("bin/tool", "binary"),
+				hardlink("bin/alias", "bin/tool"),
+			},
+			check: func(t *testing.T, dest string) {
+				if b, err := os.ReadFile(filepath.Join(dest, "bin", "alias")); err != nil || string(b) != "binary" {
+					t.Errorf("bin/alias = %q, %v; want the linked content", b, err)
+				}
+			},
+		},
+		{
+			name:    "file matching the manifest",
+			entries: []entry{file("bin/tool", "binary")},
+			files:   map[string]string{"bin/tool": sha("binary")},
+		},
+		{
+			name:    "file not matching the manifest",
+			entries: []entry{file("bin/tool", "tampered")},
+			files:   map[string]string{"bin/tool": sha("binary")},
+			errIs:   errFileMismatch,
+		},
+		{
+			name:    "file missing from the manifest",
+			entries: []entry{file("bin/extra", "x")},
+			files:   map[string]string{"bin/tool": sha("binary")},
+			errIs:   errFileMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := t.TempDir()
+			root, err := os.OpenRoot(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer root.Close()
+
+			e := &tarExtractor{root: root, mounts: map[string]string{"/nix": "toolbox/nix"}, files: tt.files}
+			err = e.extract(tar.NewReader(buildTar(t, tt.entries)))
+			switch {
+			case tt.errIs != nil:
+				if !errors.Is(err, tt.errIs) {
+					t.Fatalf("err = %v; want %v", err, tt.errIs)
+				}
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v; want one containing %q", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, dest)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"runtime"
+
+	"gradient-engineer/rules"
+)
+
+// ruleVars are the variables available to rule and check conditions.
+func ruleVars() map[string]float64 {
+	return map[string]float64{"cpus": float64(runtime.NumCPU())}
+}
+
+// ruleInput describes the outcome of cmd to the rules package.
+func ruleInput(cmd DiagnosticCommand, res *CommandResult) rules.Input {
+	command := cmd.Command
+	if cmd.Spec != nil {
+		command = cmd.Spec.Command
+	}
+	return rules.Input{
+		Command: command,
+		Program: cmd.Program,
+		Parser:  cmd.Parser,
+		Parsed:  res.Parsed,
+		Output:  res.FullStdout() + res.FullStderr(),
+	}
+}
+
+// runChecks evaluates the checks of cmd and returns the failed ones. A
+// command that could not be started has nothing to check.
+func runChecks(cmd DiagnosticCommand, res *CommandResult) []rules.Finding {
+	if res == nil || len(cmd.Checks) == 0 {
+		return nil
+	}
+	in := ruleInput(cmd, res)
+	var failed []rules.Finding
+	for _, c := range cmd.Checks {
+		if f, ok := c.Evaluate(in, ruleVars()); ok {
+			failed = append(failed, f)
+		}
+	}
+	return failed
+}
+
+// evaluateFindings runs the toolbox rules over the finished commands and
+// merges in the failed checks. results[i] is the outcome of commands[i] and
+// may be nil; failed[i] are its failed checks.
+func evaluateFindings(engine *rules.Engine, commands []DiagnosticCommand, results []*CommandResult, failed [][]rules.Finding) []rules.Finding {
+	var findings []rules.Finding
+	if engine != nil {
+		var inputs []rules.Input
+		for i, cmd := range commands {
+			if results[i] != nil {
+				inputs = append(inputs, ruleInput(cmd, results[i]))
+			}
+		}
+		findings = engine.Evaluate(inputs, ruleVars())
+	}
+	for _, f := range failed {
+		findings = append(findings, f...)
+	}
+	rules.Sort(findings)
+	return findings
+}
+
+// checksExitCode maps the worst failed check to a process exit code.
+func checksExitCode(failed [][]rules.Finding) int {
+	var all []rules.Finding
+	for _, f := range failed {
+		all = append(all, f...)
+	}
+	switch rules.Worst(all) {
+	case rules.SeverityCritical:
+		return exitCheckCritical
+	case rules.SeverityWarning:
+		return exitCheckWarning
+	default:
+		return exitOK
+	}
+}
+
+// severityIcon is the symbol used for a finding in the TUI and reports.
+func severityIcon(severity string) string {
+	switch severity {
+	case rules.SeverityCritical:
+		return "‼"
+	case rules.SeverityWarning:
+		return "!"
+	default:
+		return "i"
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gradient-engineer/rules"
+)
+
+// reportSchemaVersion is bumped whenever a field of RunReport changes
+// meaning or is removed. Adding fields does not change the version.
+const reportSchemaVersion = 1
+
+// Exit codes of a run. A failed run or command takes precedence over failed
+// checks, and a critical check over a warning.
+const (
+	exitOK            = 0 // Every command succeeded and every check passed
+	exitCommandFailed = 1 // At least one command failed
+	exitRunFailed     = 2 // The toolbox or playbook could not be loaded
+	exitCheckWarning  = 3 // A check with severity warning failed
+	exitCheckCritical = 4 // A check with severity critical failed
+)
+
+// RunReport is the machine-readable result of a playbook run.
+type RunReport struct {
+	SchemaVersion int             `json:"schema_version"`
+	Playbook      ReportPlaybook  `json:"playbook"`
+	ToolboxURL    string          `json:"toolbox_url,omitempty"`
+	Host          HostFacts       `json:"host"`
+	StartedAt     time.Time       `json:"started_at"`
+	Duration      float64         `json:"duration_seconds"`
+	Commands      []ReportCommand `json:"commands"`
+	Findings      []rules.Finding `json:"findings"`
+	Summary       ReportSummary   `json:"summary"`
+	Error         string          `json:"error,omitempty"` // Set when the run could not start
+}
+
+// ReportPlaybook identifies the playbook that was run.
+type ReportPlaybook struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// HostFacts describes the machine the playbook ran on.
+type HostFacts struct {
+	Hostname string `json:"hostname"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	Kernel   string `json:"kernel,omitempty"`
+	CPUs     int    `json:"cpus"`
+}
+
+// ReportCommand is the outcome of a single playbook command.
+type ReportCommand struct {
+	Command      string          `json:"command"`
+	Description  string          `json:"description"`
+	Argv         []string        `json:"argv"`
+	Source       string          `json:"source,omitempty"`
+	Status       string          `json:"status"` // "success", "error" or "timed_out"
+	ExitCode     int             `json:"exit_code"`
+	Signal       string          `json:"signal,omitempty"`
+	TimedOut     bool            `json:"timed_out"`
+	Duration     float64         `json:"duration_seconds"`
+	Stdout       string          `json:"stdout"`
+	Stderr       string          `json:"stderr"`
+	Error        string          `json:"error,omitempty"`
+	Parser       string          `json:"parser,omitempty"`
+	Parsed       any             `json:"parsed,omitempty"` // Structured stdout, shape depends on Parser
+	ParseError   string          `json:"parse_error,omitempty"`
+	FailedChecks []rules.Finding `json:"failed_checks,omitempty"`
+}
+
+// ReportSummary is the AI summary of the run, if one was produced.
+type ReportSummary struct {
+	Text    string `json:"text,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Skipped string `json:"skipped,omitempty"` // Reason the summary was not generated
+}
+
+// collectHostFacts gathers the host description included in reports.
+func collectHostFacts() HostFacts {
+	hostname, _ := os.Hostname()
+	kernel := ""
+	if out, err := exec.Command("uname", "-r").Output(); err == nil {
+		kernel = strings.TrimSpace(string(out))
+	}
+	return HostFacts{
+		Hostname: hostname,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Kernel:   kernel,
+		CPUs:     runtime.NumCPU(),
+	}
+}
+
+// runHeadless runs the playbook without the TUI: it downloads the toolbox,
+// executes every command in parallel, summarizes the results with the model
+// llm selects and writes the report to w as JSON or plain text, and to
+// reportPath if set. It returns the process exit code.
+func runHeadless(tb *Toolbox, llm llmLayers, w io.Writer, format, reportPath string) int {
+	report := &RunReport{
+		SchemaVersion: reportSchemaVersion,
+		Host:          collectHostFacts(),
+		StartedAt:     time.Now(),
+		Commands:      []ReportCommand{},
+		Findings:      []rules.Finding{},
+	}
+	defer stopOnSignal(tb)()
+	code, sc := executeHeadless(tb, report)
+	if report.Error == "" {
+		summarizeHeadless(tb, llm, sc, report)
+	}
+	report.Duration = time.Since(report.StartedAt).Seconds()
+
+	var err error
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = writeTextReport(w, report)
+	}
+	if err == nil && reportPath != "" {
+		err = writeReportFile(reportPath, report)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write report: %v\n", err)
+		return exitRunFailed
+	}
+	return code
+}
+
+// stopOnSignal kills the running commands of tb and exits on SIGINT or
+// SIGTERM, which would otherwise leave collectors running. The returned
+// function uninstalls the handler.
+func stopOnSignal(tb *Toolbox) func() {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sigs:
+			tb.Cleanup()
+			fmt.Fprintf(os.Stderr, "stopped by %s\n", sig)
+			os.Exit(128 + int(sig.(syscall.Signal)))
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+// executeHeadless fills report by running the same pipeline as the TUI, up to
+// the summary. It returns the exit code and the input for the summarizer.
+func executeHeadless(tb *Toolbox, report *RunReport) (int, []SummaryCommand) {
+	tb.Progress = func(p DownloadProgress) {
+		if notice := p.staleNotice(); notice != "" {
+			fmt.Fprintln(os.Stderr, notice)
+		}
+	}
+	if err := tb.Download(); err != nil {
+		report.Error = err.Error()
+		return exitRunFailed, nil
+	}
+	report.ToolboxURL = tb.URL
+
+	commands, err := tb.GetDiagnosticCommands()
+	if err != nil {
+		report.Error = err.Error()
+		return exitRunFailed, nil
+	}
+	if tb.Playbook != nil {
+		report.Playbook = ReportPlaybook{ID: tb.Playbook.ID, Name: tb.Playbook.Name}
+	}
+
+	results := make([]*CommandResult, len(commands))
+	errs := make([]error, len(commands))
+	var wg sync.WaitGroup
+	for i, cmd := range commands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = tb.RunDiagnosticCommand(cmd, nil)
+		}()
+	}
+	wg.Wait()
+
+	failed := make([][]rules.Finding, len(commands))
+	for i, cmd := range commands {
+		failed[i] = runChecks(cmd, results[i])
+	}
+	code := checksExitCode(failed)
+	var sc []SummaryCommand
+	for i, cmd := range commands {
+		if errs[i] != nil {
+			code = exitCommandFailed
+		}
+		report.Commands = append(report.Commands, newReportCommand(cmd, results[i], errs[i], failed[i]))
+		sc = append(sc, SummaryCommand{
+			Description: cmd.Spec,
+			Result:      results[i],
+			Err:         errs[i],
+		})
+	}
+
+	if findings := evaluateFindings(tb.Rules, commands, results, failed); findings != nil {
+		report.Findings = findings
+	}
+	return code, sc
+}
+
+// summarizeHeadless adds the AI summary of sc to report.
+func summarizeHeadless(tb *Toolbox, llm llmLayers, sc []SummaryCommand, report *RunReport) {
+	summarizer := llm.summarizer(tb.Playbook)
+	switch {
+	case summarizer.disabled:
+		report.Summary.Skipped = "no API key provided"
+		if summarizer.publicAvailable {
+			report.Summary.Skipped += " (pass --allow-public-llm to use free public models)"
+		}
+	case tb.Playbook == nil || tb.Playbook.SystemPrompt == "":
+		report.Summary.Error = "system_prompt is required in playbook"
+	default:
+		// stdout carries the report, so the notice goes to stderr
+		if notice := summarizer.DataNotice(); notice != "" {
+			fmt.Fprintf(os.Stderr, "AI summary: %s\n", notice)
+		}
+		summary, err := summarizer.Summarize(tb.Playbook.SystemPrompt, sc, report.Findings)
+		if err != nil {
+			report.Summary.Error = err.Error()
+		} else {
+			report.Summary.Text = summary
+		}
+	}
+}
+
+// runPreview runs the playbook like runHeadless but, instead of summarizing,
+// writes the system prompt and the redacted user message that would be sent
+// to w. Nothing is sent to the LLM.
+func runPreview(tb *Toolbox, llm llmLayers, w io.Writer) int {
+	report := &RunReport{Host: collectHostFacts(), StartedAt: time.Now()}
+	defer stopOnSignal(tb)()
+	code, sc := executeHeadless(tb, report)
+	if report.Error != "" {
+		fmt.Fprintln(os.Stderr, report.Error)
+		return code
+	}
+	summarizer := llm.summarizer(tb.Playbook)
+	if summarizer.redactor == nil {
+		// The redaction settings are invalid
+		fmt.Fprintln(os.Stderr, summarizer.err)
+		return exitRunFailed
+	}
+	systemPrompt := ""
+	if tb.Playbook != nil {
+		systemPrompt = tb.Playbook.SystemPrompt
+	}
+	message := summarizer.UserMessage(sc, report.Findings)
+	if _, err := fmt.Fprintf(w, "=== System prompt ===\n%s\n\n=== User message ===\n%s", strings.TrimRight(systemPrompt, "\n"), message); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write preview: %v\n", err)
+		return exitRunFailed
+	}
+
+	counts := summarizer.redactor.Counts()
+	var kinds []string
+	for name, n := range counts {
+		kinds = append(kinds, fmt.Sprintf("%s %d", name, n))
+	}
+	sort.Strings(kinds)
+	if len(kinds) == 0 {
+		kinds = []string{"none"}
+	}
+	fmt.Fprintf(os.Stderr, "\nRedacted values: %s\n", strings.Join(kinds, ", "))
+	if label := summarizer.Label(); label != "" {
+		fmt.Fprintf(os.Stderr, "Nothing was sent; without --show-redacted this goes to %s.\n", label)
+	} else {
+		fmt.Fprintln(os.Stderr, "Nothing was sent; no LLM provider is configured.")
+	}
+	return code
+}
+
+// newReportCommand records the outcome of cmd and its failed checks. res may
+// be nil if the command could not be started.
+func newReportCommand(cmd DiagnosticCommand, res *CommandResult, err error, failed []rules.Finding) ReportCommand {
+	rc := ReportCommand{
+		Command:      cmd.Command,
+		Description:  cmd.Display,
+		Argv:         cmd.Argv,
+		Source:       cmd.Source,
+		Parser:       cmd.Parser,
+		FailedChecks: failed,
+		Status:       "success",
+		ExitCode:     -1,
+	}
+	if cmd.Spec != nil {
+		rc.Command = cmd.Spec.Command
+	}
+	if rc.Argv == nil {
+		rc.Argv = []string{}
+	}
+	if res != nil {
+		rc.ExitCode = res.ExitCode
+		rc.Signal = res.Signal
+		rc.TimedOut = res.TimedOut
+		rc.Duration = res.Duration().Seconds()
+		rc.Stdout = res.FullStdout()
+		rc.Stderr = res.FullStderr()
+		rc.Parsed = res.Parsed
+		if res.ParseErr != nil {
+			rc.ParseError = res.ParseErr.Error()
+		}
+	}
+	if err != nil {
+		rc.Status = "error"
+		rc.Error = err.Error()
+	}
+	if res != nil && res.TimedOut {
+		rc.Status = "timed_out"
+	}
+	return rc
+}
+
+// statusIcon is the symbol used for a command status in text reports.
+func statusIcon(status string) string {
+	switch status {
+	case "success":
+		return "✓"
+	case "timed_out":
+		return "⧗"
+	default:
+		return "✗"
+	}
+}
+
+// writeTextReport prints report in a plain, human-readable form.
+func writeTextReport(w io.Writer, report *RunReport) error {
+	var b strings.Builder
+	if report.Playbook.Name != "" {
+		fmt.Fprintf(&b, "%s\n\n", report.Playbook.Name)
+	}
+	if report.Error != "" {
+		fmt.Fprintf(&b, "✗ %s\n", report.Error)
+	}
+	for _, c := range report.Commands {
+		fmt.Fprintf(&b, "%s %s — %s (%.1fs)\n", statusIcon(c.Status), c.Command, c.Description, c.Duration)
+		if out := strings.TrimRight(c.Stdout+c.Stderr, "\n"); out != "" {
+			b.WriteString(indent(out, "    "))
+			b.WriteString("\n")
+		}
+		if c.Error != "" {
+			b.WriteString(indent("ERROR: "+c.Error, "    "))
+			b.WriteString("\n")
+		}
+	}
+	if len(report.Findings) > 0 {
+		b.WriteString("\nFindings\n\n")
+		for _, f := range report.Findings {
+			fmt.Fprintf(&b, "%s %s (%s)\n", severityIcon(f.Severity), f.Message, f.Command)
+		}
+	}
+	switch {
+	case report.Summary.Text != "":
+		fmt.Fprintf(&b, "\nAI Summary\n\n%s\n", report.Summary.Text)
+	case report.Summary.Error != "":
+		fmt.Fprintf(&b, "\nLLM error: %s\n", report.Summary.Error)
+	case report.Summary.Skipped != "":
+		fmt.Fprintf(&b, "\nAI summary skipped: %s\n", report.Summary.Skipped)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// APIs spoken by local LLM servers.
+const (
+	localAPIOllama = "ollama" // Ollama's native /api/chat
+	localAPIOpenAI = "openai" // OpenAI-compatible /v1/chat/completions (llama.cpp, vLLM, LM Studio, Ollama)
+)
+
+// localCandidates are the servers probed on localhost when no provider is
+// configured, in order.
+var localCandidates = []string{
+	"http://127.0.0.1:11434",   // Ollama
+	"http://127.0.0.1:8080/v1", // llama.cpp server
+	"http://127.0.0.1:1234/v1", // LM Studio
+	"http://127.0.0.1:8000/v1", // vLLM
+}
+
+// localProbeTimeout bounds each request made while looking for a server.
+const localProbeTimeout = 500 * time.Millisecond
+
+// localLLM describes a reachable local server.
+type localLLM struct {
+	BaseURL string // Without a trailing slash; ends in /v1 for OpenAI-compatible servers
+	API     string // localAPIOllama or localAPIOpenAI
+	Model   string
+}
+
+// localFromEnv returns the local server configured with LOCAL_LLM_BASE_URL
+// or OLLAMA_HOST. model, or else LOCAL_LLM_MODEL, selects the model; a
+// server given without one is asked for its first. Only LOCAL_LLM_BASE_URL
+// is an explicit choice: OLLAMA_HOST is often set for the server itself, so
+// a server that does not answer there is ignored.
+func localFromEnv(model string) (*localLLM, error) {
+	if base := os.Getenv("LOCAL_LLM_BASE_URL"); base != "" {
+		return probeLocal(normalizeLocalURL(base), localModel(model), false)
+	}
+	if host := os.Getenv("OLLAMA_HOST"); host != "" {
+		if l, err := probeLocal(ollamaURL(host), localModel(model), false); err == nil {
+			return l, nil
+		}
+	}
+	return nil, nil
+}
+
+// detectLocal looks for a server listening on one of the well-known local
+// ports and returns the first one that offers a model. Ports such as 8080 are
+// used by all kinds of services, so only a reply that is plainly a model list
+// counts.
+func detectLocal(model string) *localLLM {
+	for _, base := range localCandidates {
+		if l, err := probeLocal(base, localModel(model), true); err == nil {
+			return l
+		}
+	}
+	return nil
+}
+
+// findLocal returns the server at base, or the configured or detected one if
+// base is empty.
+func findLocal(base, model string) (*localLLM, error) {
+	if base != "" {
+		return probeLocal(normalizeLocalURL(base), localModel(model), false)
+	}
+	l, err := localFromEnv(model)
+	if l != nil || err != nil {
+		return l, err
+	}
+	if l := detectLocal(model); l != nil {
+		return l, nil
+	}
+	return nil, fmt.Errorf("no local LLM server found; set LOCAL_LLM_BASE_URL or --llm-base-url")
+}
+
+// localModel returns model, falling back to LOCAL_LLM_MODEL.
+func localModel(model string) string {
+	if model == "" {
+		return os.Getenv("LOCAL_LLM_MODEL")
+	}
+	return model
+}
+
+// normalizeLocalURL accepts "host:port" as OLLAMA_HOST does and strips
+// trailing slashes. A server bound to all interfaces is reached on loopback.
+func normalizeLocalURL(base string) string {
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	base = strings.TrimRight(base, "/")
+	u, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+	switch u.Hostname() {
+	case "0.0.0.0":
+		u.Host = replaceHost(u, "127.0.0.1")
+	case "::":
+		u.Host = replaceHost(u, "::1")
+	}
+	return u.String()
+}
+
+// ollamaURL turns OLLAMA_HOST into a base URL. Like Ollama, it defaults to
+// port 11434 only when no scheme is given, so "0.0.0.0" means
+// http://127.0.0.1:11434 but "https://ollama.example.com" keeps port 443.
+func ollamaURL(host string) string {
+	base := normalizeLocalURL(host)
+	u, err := url.Parse(base)
+	if err != nil || u.Port() != "" || strings.Contains(host, "://") {
+		return base
+	}
+	u.Host = net.JoinHostPort(u.Hostname(), "11434")
+	return u.String()
+}
+
+// replaceHost returns the host:port of u with the host replaced.
+func replaceHost(u *url.URL, host string) string {
+	if u.Port() == "" {
+		if strings.Contains(host, ":") {
+			return "[" + host + "]"
+		}
+		return host
+	}
+	return net.JoinHostPort(host, u.Port())
+}
+
+// probeLocal finds out which API the server at base speaks and, if model is
+// empty, picks the first model it lists. Bases ending in /v1 are taken to be
+// OpenAI-compatible. If strict, such a server must answer with a model list
+// object rather than any JSON with a data field.
+func probeLocal(base, model string, strict bool) (*localLLM, error) {
+	client := &http.Client{Timeout: localProbeTimeout}
+	if !strings.HasSuffix(base, "/v1") {
+		var tags struct {
+			Models []struct {
+				Name string `json:"name"`
+			} `json:"models"`
+		}
+		if err := getJSON(client, base+"/api/tags", &tags); err == nil {
+			l := &localLLM{BaseURL: base, API: localAPIOllama, Model: model}
+			if l.Model == "" && len(tags.Models) > 0 {
+				l.Model = tags.Models[0].Name
+			}
+			if l.Model == "" {
+				return nil, fmt.Errorf("no models installed in Ollama at %s", base)
+			}
+			return l, nil
+		}
+		base += "/v1"
+	}
+	var models struct {
+		Object string `json:"object"`
+		Data   []struct {
+			ID     string `json:"id"`
+			Object string `json:"object"`
+		} `json:"data"`
+	}
+	if err := getJSON(client, base+"/models", &models); err != nil {
+		return nil, fmt.Errorf("no local LLM server at %s: %w", strings.TrimSuffix(base, "/v1"), err)
+	}
+	if strict {
+		if models.Object != "list" || len(models.Data) == 0 {
+			return nil, fmt.Errorf("%s/models is not a model list", base)
+		}
+		for _, m := range models.Data {
+			if m.Object != "model" || m.ID == "" {
+				return nil, fmt.Errorf("%s/models is not a model list", base)
+			}
+		}
+	}
+	l := &localLLM{BaseURL: base, API: localAPIOpenAI, Model: model}
+	if l.Model == "" && len(models.Data) > 0 {
+		l.Model = models.Data[0].ID
+	}
+	if l.Model == "" {
+		return nil, fmt.Errorf("local LLM server at %s lists no models", base)
+	}
+	return l, nil
+}
+
+// getJSON fetches url and decodes the JSON response into v.
+func getJSON(client *http.Client, url string, v any) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// streamOllama sends the prompt to Ollama's native chat endpoint and feeds
+// the streamed reply to emit. maxTokens and temperature are left to the
+// model's defaults when zero and nil.
+func streamOllama(ctx context.Context, l *localLLM, systemPrompt, userContent string, maxTokens int, temperature *float64, emit func(string)) error {
+	options := map[string]any{}
+	if maxTokens > 0 {
+		options["num_predict"] = maxTokens
+	}
+	if temperature != nil {
+		options["temperature"] = *temperature
+	}
+	body, err := json.Marshal(map[string]any{
+		"model": l.Model,
+		"messages": []map[string]string{
+			{"role": "system", "content": systemPrompt},
+			{"role": "user", "content": userContent},
+		},
+		"options": options,
+		"stream":  true,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.BaseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// The reply is one JSON object per line; errors come the same way
+	type chunk struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Done  bool   `json:"done"`
+		Error string `json:"error"`
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var c chunk
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			return fmt.Errorf("failed to parse Ollama response: %w", err)
+		}
+		if c.Error != "" {
+			return fmt.Errorf("ollama: %s", c.Error)
+		}
+		emit(c.Message.Content)
+		if c.Done {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read Ollama response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama: %s", resp.Status)
+	}
+	return fmt.Errorf("ollama: response ended early")
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gradient-engineer/playbook"
+)
+
+// stubOllama serves /api/tags and streams chat as the given NDJSON lines.
+func stubOllama(t *testing.T, chat ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"llama3.1:8b"},{"name":"qwen2.5:7b"}]}`)
+		case "/api/chat":
+			var req struct {
+				Model    string `json:"model"`
+				Stream   bool   `json:"stream"`
+				Messages []struct {
+					Role    string `json:"role"`
+					Content string `json:"content"`
+				} `json:"messages"`
+				Options map[string]any `json:"options"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !req.Stream || len(req.Messages) != 2 || req.Messages[0].Role != "system" || !strings.Contains(req.Messages[1].Content, "outputs") {
+				http.Error(w, "unexpected request", http.StatusBadRequest)
+				return
+			}
+			if req.Options["num_predict"] != float64(100) || req.Options["temperature"] != 0.5 {
+				http.Error(w, "options not passed", http.StatusBadRequest)
+				return
+			}
+			for _, line := range chat {
+				fmt.Fprintln(w, line)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// stubOpenAI serves the model list of an OpenAI-compatible server.
+func stubOpenAI(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"qwen2.5-coder","object":"model"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProbeLocal(t *testing.T) {
+	ollama := stubOllama(t)
+	openai := stubOpenAI(t)
+
+	tests := []struct {
+		name  string
+		base  string
+		model string
+		want  localLLM
+	}{
+		{"ollama", ollama.URL, "", localLLM{BaseURL: ollama.URL, API: localAPIOllama, Model: "llama3.1:8b"}},
+		{"ollama with model", ollama.URL, "qwen2.5:7b", localLLM{BaseURL: ollama.URL, API: localAPIOllama, Model: "qwen2.5:7b"}},
+		{"openai-compatible", openai.URL, "", localLLM{BaseURL: openai.URL + "/v1", API: localAPIOpenAI, Model: "qwen2.5-coder"}},
+		{"openai-compatible with /v1", openai.URL + "/v1", "", localLLM{BaseURL: openai.URL + "/v1", API: localAPIOpenAI, Model: "qwen2.5-coder"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := probeLocal(tt.base, tt.model, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *l != tt.want {
+				t.Errorf("probeLocal(%s) = %+v; want %+v", tt.base, *l, tt.want)
+			}
+		})
+	}
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	if _, err := probeLocal(closed.URL, "", false); err == nil {
+		t.Error("probeLocal succeeded against a closed server")
+	}
+}
+
+func TestDetectLocal(t *testing.T) {
+	// Some other service on a well-known port that happens to serve JSON
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[{"id":"dashboard"}]}`)
+	}))
+	defer other.Close()
+	openai := stubOpenAI(t)
+
+	if _, err := probeLocal(other.URL+"/v1", "", false); err != nil {
+		t.Errorf("explicit server rejected: %v", err)
+	}
+	old := localCandidates
+	defer func() { localCandidates = old }()
+	localCandidates = []string{other.URL + "/v1"}
+	if l := detectLocal(""); l != nil {
+		t.Errorf("detectLocal = %+v; want nothing", *l)
+	}
+	localCandidates = []string{other.URL + "/v1", openai.URL + "/v1"}
+	if l := detectLocal(""); l == nil || l.BaseURL != openai.URL+"/v1" {
+		t.Errorf("detectLocal = %v; want %s/v1", l, openai.URL)
+	}
+}
+
+func TestStreamOllama(t *testing.T) {
+	tests := []struct {
+		name    string
+		chat    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "complete",
+			chat: []string{
+				`{"message":{"role":"assistant","content":"Load is "},"done":false}`,
+				`{"message":{"role":"assistant","content":"fine."},"done":false}`,
+				`{"message":{"role":"assistant","content":""},"done":true}`,
+			},
+			want: "Load is fine.",
+		},
+		{
+			name: "error line",
+			chat: []string{
+				`{"message":{"role":"assistant","content":"Load"},"done":false}`,
+				`{"error":"model requires more system memory"}`,
+			},
+			want:    "Load",
+			wantErr: "model requires more system memory",
+		},
+		{
+			name: "ends early",
+			chat: []string{
+				`{"message":{"role":"assistant","content":"Load is"},"done":false}`,
+			},
+			want:    "Load is",
+			wantErr: "ended early",
+		},
+	}
+	temperature := 0.5
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := stubOllama(t, tt.chat...)
+			l := &localLLM{BaseURL: srv.URL, API: localAPIOllama, Model: "llama3.1:8b"}
+			var got strings.Builder
+			err := streamOllama(context.Background(), l, "system", "outputs", 100, &temperature, func(s string) { got.WriteString(s) })
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("err = %v; want one containing %q", err, tt.wantErr)
+			}
+			if got.String() != tt.want {
+				t.Errorf("streamed %q; want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizerOllama(t *testing.T) {
+	srv := stubOllama(t,
+		`{"message":{"content":"All good."},"done":false}`,
+		`{"message":{"content":""},"done":true}`,
+	)
+	t.Setenv("LOCAL_LLM_BASE_URL", srv.URL)
+	t.Setenv("LOCAL_LLM_MODEL", "")
+	temperature := 0.5
+	s := NewSummarizer(playbook.LLMConfig{MaxTokens: 100, Temperature: &temperature}, publicLLMAsk)
+	if s.provider != "local" || s.err != nil {
+		t.Fatalf("provider = %s, err = %v; want local", s.provider, s.err)
+	}
+	res := &CommandResult{Stdout: "outputs"}
+	summary, err := s.Summarize("system", []SummaryCommand{{Result: res}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != "All good." {
+		t.Errorf("summary = %q", summary)
+	}
+}
+
+func TestLocalFromEnv(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	t.Run("unreachable OLLAMA_HOST is ignored", func(t *testing.T) {
+		t.Setenv("LOCAL_LLM_BASE_URL", "")
+		t.Setenv("OLLAMA_HOST", strings.TrimPrefix(closed.URL, "http://"))
+		l, err := localFromEnv("")
+		if l != nil || err != nil {
+			t.Errorf("localFromEnv = %v, %v; want nothing", l, err)
+		}
+	})
+	t.Run("unreachable LOCAL_LLM_BASE_URL is an error", func(t *testing.T) {
+		t.Setenv("LOCAL_LLM_BASE_URL", closed.URL)
+		if _, err := localFromEnv(""); err == nil {
+			t.Error("localFromEnv succeeded against a closed server")
+		}
+	})
+	t.Run("OLLAMA_HOST does not hide API keys", func(t *testing.T) {
+		t.Setenv("LOCAL_LLM_BASE_URL", "")
+		t.Setenv("OLLAMA_HOST", strings.TrimPrefix(closed.URL, "http://"))
+		t.Setenv("ANTHROPIC_API_KEY", "test-key")
+		s := NewSummarizer(playbook.LLMConfig{}, publicLLMAsk)
+		if s.provider != "anthropic" || s.err != nil {
+			t.Errorf("provider = %s, err = %v; want anthropic", s.provider, s.err)
+		}
+	})
+}
+
+func TestOllamaURL(t *testing.T) {
+	tests := []struct{ host, want string }{
+		{"0.0.0.0", "http://127.0.0.1:11434"},
+		{"0.0.0.0:8081", "http://127.0.0.1:8081"},
+		{"[::]:11434", "http://[::1]:11434"},
+		{"gpu-box", "http://gpu-box:11434"},
+		{"https://ollama.example.com/", "https://ollama.example.com"},
+		{"http://gpu-box", "http://gpu-box"},
+		{"http://localhost:11434", "http://localhost:11434"},
+		{"http://0.0.0.0", "http://127.0.0.1"},
+	}
+	for _, tt := range tests {
+		if got := ollamaURL(tt.host); got != tt.want {
+			t.Errorf("ollamaURL(%q) = %q; want %q", tt.host, got, tt.want)
+		}
+	}
+}
@@ -1,15 +1,43 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"fmt"
 	"log"
+	"os"
 	"runtime"
+	"text/tabwriter"
+
+	"gradient-engineer/manifest"
+	"gradient-engineer/playbook"
 
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/spf13/cobra"
 )
 
 var (
-	toolboxRepo string
+	toolboxRepos   []string
+	configPath     string
+	caCert         string
+	offline        bool
+	cacheDir       string
+	cacheMaxSizeMB int64
+	pruneAll       bool
+	publicKey      string
+	skipVerify     bool
+	playbookFile   string
+	resolvePolicy  string
+	outputFormat   string
+	noTUI          bool
+	reportPath     string
+	llmProvider    string
+	llmModel       string
+	llmBaseURL     string
+	llmMaxTokens   int
+	llmTemperature float64
+	allowPublicLLM bool
+	noPublicLLM    bool
+	showRedacted   bool
 )
 
 func main() {
@@ -32,24 +60,274 @@ repository based on your platform (OS and architecture).`,
 				}
 			}
 
+			// A local playbook names its toolbox by id unless one is given
+			if playbookFile != "" && len(args) == 0 {
+				pb, err := loadPlaybook(playbookFile)
+				if err != nil {
+					log.Fatal(err)
+				}
+				if pb.ID != "" {
+					playbookName = pb.ID
+				}
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			// Repositories from the command line replace the configured list
+			repos := cfg.ToolboxRepos
+			if cmd.Flags().Changed("toolbox-repo") || len(repos) == 0 {
+				repos = toolboxRepos
+			}
+			var bases []string
+			for _, repo := range repos {
+				base, err := repoBaseURL(repo)
+				if err != nil {
+					log.Fatal(err)
+				}
+				bases = append(bases, base)
+			}
+
+			ca := cfg.CACert
+			if caCert != "" {
+				ca = caCert
+			}
+			client, err := newHTTPClient(ca)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			cache, err := openCache()
+			if err != nil {
+				log.Fatal(err)
+			}
+			// A local playbook only reuses toolboxes that are already cached
+			cache.Offline = offline || playbookFile != ""
+			cache.Client = client
+
+			policy, err := ParseResolvePolicy(resolvePolicy)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			var pubKey ed25519.PublicKey
+			if publicKey != "" {
+				pubKey, err = manifest.ParsePublicKey(publicKey)
+			} else {
+				pubKey, err = PinnedPublicKey()
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			switch outputFormat {
+			case "tui", "json", "text":
+			default:
+				log.Fatalf("unknown output format %q (want tui, json or text)", outputFormat)
+			}
+			if noTUI && outputFormat == "tui" {
+				outputFormat = "text"
+			}
+			if reportPath != "" {
+				if _, err := reportFormat(reportPath); err != nil {
+					log.Fatal(err)
+				}
+			}
+
+			// The playbook's llm: block goes between the config file and the flags
+			llm := llmLayers{
+				Config: cfg.LLM,
+				Flags:  playbook.LLMConfig{Provider: llmProvider, Model: llmModel, BaseURL: llmBaseURL, MaxTokens: llmMaxTokens},
+				Redact: cfg.Redact,
+			}
+			if cmd.Flags().Changed("temperature") {
+				llm.Flags.Temperature = &llmTemperature
+			}
+			switch {
+			case noPublicLLM:
+				llm.Public = publicLLMDisabled
+			case allowPublicLLM:
+				llm.Public = publicLLMAllowed
+			}
+			for _, provider := range []string{cfg.LLM.Provider, llmProvider} {
+				if err := checkLLMProvider(provider); err != nil {
+					log.Fatal(err)
+				}
+			}
+
 			// Create a new toolbox instance
-			tb := NewToolbox(toolboxRepo, playbookName)
+			tb := NewToolbox(bases, playbookName, cache)
 			defer tb.Cleanup()
+			tb.PublicKey = pubKey
+			tb.SkipVerify = skipVerify
+			tb.PlaybookFile = playbookFile
+			tb.Resolve = policy
+
+			if showRedacted {
+				code := runPreview(tb, llm, os.Stdout)
+				tb.Cleanup()
+				os.Exit(code)
+			}
+
+			if outputFormat != "tui" {
+				code := runHeadless(tb, llm, os.Stdout, outputFormat, reportPath)
+				tb.Cleanup()
+				os.Exit(code)
+			}
 
 			// Create and run the Bubble Tea program which will handle toolbox download and diagnostics
-			p := tea.NewProgram(NewModel(tb), tea.WithMouseCellMotion())
+			m := NewModel(tb, llm)
+			p := tea.NewProgram(m, tea.WithMouseCellMotion())
 			if _, err := p.Run(); err != nil {
+				tb.Cleanup()
 				log.Fatalf("Error running Bubble Tea program: %v", err)
 			}
+			if reportPath != "" {
+				if err := writeReportFile(reportPath, m.runReport()); err != nil {
+					log.Fatal(err)
+				}
+				fmt.Printf("report written to %s\n", reportPath)
+			}
+			if code := m.exitCode(); code != exitOK {
+				tb.Cleanup()
+				os.Exit(code)
+			}
+		},
+	}
+
+	var cacheCmd = &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and prune the local toolbox cache",
+	}
+
+	var cacheListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List cached toolbox archives and manifests",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := openCache()
+			if err != nil {
+				return err
+			}
+			entries, err := cache.List()
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				fmt.Printf("cache %s is empty\n", cache.Dir)
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "DIGEST\tKIND\tSIZE\tLAST USED\tURL")
+			for _, e := range entries {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Digest[:min(12, len(e.Digest))], e.Kind(), formatBytes(e.Size), e.LastUsed.Format("2006-01-02 15:04"), e.URL)
+			}
+			return w.Flush()
+		},
+	}
+
+	var cachePruneCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "Evict cached toolbox archives beyond the size limit",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := openCache()
+			if err != nil {
+				return err
+			}
+			removed, freed, err := cache.Prune(cache.MaxBytes, pruneAll)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("removed %d entries, freed %s\n", removed, formatBytes(freed))
+			return nil
 		},
 	}
 
 	// Define flags
-	rootCmd.Flags().StringVar(&toolboxRepo, "toolbox-repo", "https://gradient.engineer/toolbox/",
-		"Toolbox repository URL or path (e.g., file:///home/user/mytoolboxes/)")
+	rootCmd.Flags().StringSliceVar(&toolboxRepos, "toolbox-repo", []string{"https://gradient.engineer/toolbox/"},
+		"Toolbox repository URL or path, repeatable and tried in order (e.g., file:///home/user/mytoolboxes/, s3://bucket/prefix/)")
+	rootCmd.Flags().StringVar(&caCert, "ca-cert", "", "PEM bundle of additional CAs trusted for toolbox downloads")
+	rootCmd.Flags().BoolVar(&offline, "offline", false, "Only use toolboxes that are already cached")
+	rootCmd.Flags().StringVar(&playbookFile, "playbook-file", "",
+		"Run a local playbook YAML against a cached toolbox or the host PATH instead of downloading one")
+	rootCmd.Flags().StringVar(&resolvePolicy, "resolve", "",
+		"Where to look up command binaries: toolbox-only, toolbox-then-host or host-only (default toolbox-only on Linux, toolbox-then-host on macOS or with --playbook-file)")
+	rootCmd.Flags().StringVar(&outputFormat, "output", "tui",
+		"Output format: tui, or json/text to run non-interactively and print the results to stdout")
+	rootCmd.Flags().BoolVar(&noTUI, "no-tui", false, "Run non-interactively (same as --output text unless --output is given)")
+	rootCmd.Flags().StringVar(&reportPath, "report", "",
+		"Write a Markdown (.md) or HTML (.html) report with full command output once the run ends")
+	rootCmd.Flags().StringVar(&publicKey, "toolbox-pubkey", "",
+		"Base64 ed25519 public key the toolbox manifest must be signed with (overrides the built-in key)")
+	rootCmd.Flags().BoolVar(&skipVerify, "skip-verify", false,
+		"Do not verify the toolbox archive against its manifest (unsafe)")
+	rootCmd.Flags().StringVar(&llmProvider, "llm-provider", "",
+		"LLM provider for the summary: anthropic, openai, openrouter or local (default: picked from the environment)")
+	rootCmd.Flags().StringVar(&llmModel, "llm-model", "", "Model used for the summary (default depends on the provider)")
+	rootCmd.Flags().StringVar(&llmBaseURL, "llm-base-url", "", "API endpoint of the LLM provider, e.g. a proxy or a local server")
+	rootCmd.Flags().IntVar(&llmMaxTokens, "max-tokens", 0, "Maximum length of the summary in tokens (default: provider's limit; 4096 for Anthropic)")
+	rootCmd.Flags().Float64Var(&llmTemperature, "temperature", 0, "Sampling temperature for the summary (default: provider's default)")
+	rootCmd.Flags().BoolVar(&allowPublicLLM, "allow-public-llm", false,
+		"Without an API key or local server, send command output to free public models through OpenRouter with a shared key")
+	rootCmd.Flags().BoolVar(&noPublicLLM, "no-public-llm", os.Getenv("GRADIENT_ENGINEER_NO_PUBLIC_LLM") != "",
+		"Never use the shared key, even with --allow-public-llm (default from GRADIENT_ENGINEER_NO_PUBLIC_LLM)")
+	rootCmd.Flags().BoolVar(&showRedacted, "show-redacted", false,
+		"Run the playbook non-interactively and print the redacted prompt instead of sending it to the LLM")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "",
+		"Config file (default $XDG_CONFIG_HOME/gradient-engineer/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "",
+		"Toolbox cache directory (default $XDG_CACHE_HOME/gradient-engineer)")
+	rootCmd.PersistentFlags().Int64Var(&cacheMaxSizeMB, "cache-max-size", 1024, "Maximum size of the toolbox cache in MB (0 for no limit)")
+	cachePruneCmd.Flags().BoolVar(&pruneAll, "all", false, "Remove every cached archive")
+
+	cacheCmd.AddCommand(cacheListCmd, cachePruneCmd)
+	rootCmd.AddCommand(cacheCmd)
 
 	// Execute the command
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// loadConfig reads the config file selected by --config or the default one.
+func loadConfig() (*Config, error) {
+	path := configPath
+	if path == "" {
+		var err error
+		path, err = DefaultConfigPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return LoadConfig(path)
+}
+
+// openCache returns the toolbox cache configured by the command line flags.
+func openCache() (*ToolboxCache, error) {
+	dir := cacheDir
+	if dir == "" {
+		var err error
+		dir, err = DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewToolboxCache(dir, cacheMaxSizeMB*1024*1024), nil
+}
+
+// formatBytes renders n as a human-readable size.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/v2/viewport"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+var (
+	matchStyle         = lipgloss.NewStyle().Foreground(lipgloss.Color("0")).Background(lipgloss.Color("214"))
+	selectedMatchStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("0")).Background(lipgloss.Color("205"))
+)
+
+// pager shows the output of a single command full-screen, with less-style
+// search: "/" opens the prompt, n and N jump between matches.
+type pager struct {
+	title   string
+	content string
+	vp      viewport.Model
+
+	typing  bool   // The search prompt is open
+	input   string // Query being typed
+	query   string // Last query searched for
+	matches int
+}
+
+// newPager creates a pager for content sized to a width x height terminal.
+func newPager(title, content string, width, height int) *pager {
+	vp := viewport.New(viewport.WithWidth(0), viewport.WithHeight(0))
+	vp.MouseWheelEnabled = true
+	vp.HighlightStyle = matchStyle
+	vp.SelectedHighlightStyle = selectedMatchStyle
+	// Highlight offsets are byte offsets into the content, which must be
+	// free of tabs and escape sequences for them to line up
+	content = strings.ReplaceAll(content, "\t", "    ")
+	vp.SetContent(content)
+	p := &pager{title: title, content: content, vp: vp}
+	p.setSize(width, height)
+	return p
+}
+
+// setSize fits the pager to the terminal, leaving a title and a status line.
+func (p *pager) setSize(width, height int) {
+	p.vp.SetWidth(width)
+	p.vp.SetHeight(max(height-2, 1))
+}
+
+// Update handles a message while the pager is open. It reports whether the
+// pager was closed.
+func (p *pager) Update(msg tea.Msg) (closed bool, cmd tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		p.vp, cmd = p.vp.Update(msg)
+		return false, cmd
+	}
+	if p.typing {
+		switch key.String() {
+		case "enter":
+			p.typing = false
+			p.search(p.input)
+		case "esc":
+			p.typing = false
+		case "backspace":
+			if r := []rune(p.input); len(r) > 0 {
+				p.input = string(r[:len(r)-1])
+			}
+		default:
+			p.input += key.Key().Text
+		}
+		return false, nil
+	}
+	switch key.String() {
+	case "q", "esc":
+		return true, nil
+	case "/":
+		p.typing = true
+		p.input = ""
+	case "n":
+		p.vp.HighlightNext()
+	case "N":
+		p.vp.HighlightPrevious()
+	case "g", "home":
+		p.vp.GotoTop()
+	case "G", "end":
+		p.vp.GotoBottom()
+	default:
+		p.vp, cmd = p.vp.Update(msg)
+	}
+	return false, cmd
+}
+
+// search highlights every occurrence of query. The search ignores case
+// unless query contains an upper-case letter.
+func (p *pager) search(query string) {
+	p.query = query
+	p.matches = 0
+	p.vp.ClearHighlights()
+	if query == "" {
+		return
+	}
+	expr := regexp.QuoteMeta(query)
+	if !strings.ContainsFunc(query, unicode.IsUpper) {
+		expr = "(?i)" + expr
+	}
+	matches := regexp.MustCompile(expr).FindAllStringIndex(p.content, -1)
+	p.matches = len(matches)
+	p.vp.SetHighlights(matches)
+}
+
+// View renders the title, the output and the status line.
+func (p *pager) View() string {
+	var status string
+	switch {
+	case p.typing:
+		status = "/" + p.input
+	case p.query != "" && p.matches == 0:
+		status = errorStyle.Render(fmt.Sprintf("Pattern not found: %s", p.query))
+	case p.query != "":
+		status = footerStyle.Render(fmt.Sprintf("%d matches for %q; n/N: next/previous; /: search; q: back", p.matches, p.query))
+	default:
+		status = footerStyle.Render(fmt.Sprintf("%d%%; /: search; g/G: top/bottom; q: back", int(p.vp.ScrollPercent()*100)))
+	}
+	return titleStyle.Render(p.title) + "\n" + p.vp.View() + "\n" + status
+}
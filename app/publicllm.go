@@ -0,0 +1,24 @@
+//go:build !nopublicllm
+
+package main
+
+import "embed"
+
+// fk holds the shared OpenRouter key used for free public models when the
+// user passes --allow-public-llm. Build with -tags nopublicllm to leave it out.
+//
+//go:embed .fk*.txt
+var fk embed.FS
+
+func getFK() string {
+	fk1, err1 := fk.ReadFile(".fk1.txt")
+	fk2, err2 := fk.ReadFile(".fk2.txt")
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+	fk3 := make([]byte, len(fk1))
+	for i := 0; i < len(fk1); i++ {
+		fk3[i] = byte((int(fk1[i]^fk2[i]) + 256 - i - 42) ^ 0xFF)
+	}
+	return string(fk3)
+}
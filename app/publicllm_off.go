@@ -0,0 +1,8 @@
+//go:build nopublicllm
+
+package main
+
+// getFK returns no key: this build cannot fall back to free public models.
+func getFK() string {
+	return ""
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// reportFormat picks the report writer from the file extension of path.
+func reportFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return "markdown", nil
+	case ".html", ".htm":
+		return "html", nil
+	default:
+		return "", fmt.Errorf("report %s must end in .md or .html", path)
+	}
+}
+
+// writeReportFile writes report to path as Markdown or HTML depending on its
+// extension.
+func writeReportFile(path string, report *RunReport) error {
+	format, err := reportFormat(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	if format == "html" {
+		err = writeHTMLReport(f, report)
+	} else {
+		err = writeMarkdownReport(f, report)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// reportTitle is the heading used by both report formats.
+func reportTitle(report *RunReport) string {
+	switch {
+	case report.Playbook.Name != "":
+		return report.Playbook.Name
+	case report.Playbook.ID != "":
+		return report.Playbook.ID
+	default:
+		return "gradient-engineer report"
+	}
+}
+
+// hostLine describes the host in one line.
+func hostLine(h HostFacts) string {
+	parts := []string{h.OS + "/" + h.Arch}
+	if h.Kernel != "" {
+		parts = append(parts, "kernel "+h.Kernel)
+	}
+	parts = append(parts, fmt.Sprintf("%d CPUs", h.CPUs))
+	return fmt.Sprintf("%s (%s)", h.Hostname, strings.Join(parts, ", "))
+}
+
+// writeMarkdownReport renders report as a Markdown document suitable for
+// pasting into an incident ticket.
+func writeMarkdownReport(w io.Writer, report *RunReport) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", reportTitle(report))
+	if report.Playbook.ID != "" {
+		fmt.Fprintf(&b, "- **Playbook:** `%s`\n", report.Playbook.ID)
+	}
+	fmt.Fprintf(&b, "- **Started:** %s\n", report.StartedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- **Duration:** %.1f s\n", report.Duration)
+	fmt.Fprintf(&b, "- **Host:** %s\n", hostLine(report.Host))
+	if report.ToolboxURL != "" {
+		fmt.Fprintf(&b, "- **Toolbox:** %s\n", report.ToolboxURL)
+	}
+	if report.Error != "" {
+		fmt.Fprintf(&b, "\n**Error:** %s\n", report.Error)
+	}
+
+	if len(report.Findings) > 0 {
+		b.WriteString("\n## Findings\n\n")
+		for _, f := range report.Findings {
+			fmt.Fprintf(&b, "- **%s** %s (`%s`)\n", f.Severity, f.Message, f.Command)
+		}
+	}
+
+	b.WriteString("\n## AI Summary\n\n")
+	switch {
+	case report.Summary.Text != "":
+		b.WriteString(strings.TrimSpace(report.Summary.Text))
+		b.WriteString("\n")
+	case report.Summary.Error != "":
+		fmt.Fprintf(&b, "_LLM error: %s_\n", report.Summary.Error)
+	case report.Summary.Skipped != "":
+		fmt.Fprintf(&b, "_Skipped: %s_\n", report.Summary.Skipped)
+	default:
+		b.WriteString("_Not available._\n")
+	}
+
+	b.WriteString("\n## Commands\n")
+	for _, c := range report.Commands {
+		fmt.Fprintf(&b, "\n### %s `%s`", statusIcon(c.Status), c.Command)
+		if c.Description != "" {
+			fmt.Fprintf(&b, " — %s", c.Description)
+		}
+		fmt.Fprintf(&b, "\n\nStatus: %s, exit code %d, %.2f s", c.Status, c.ExitCode, c.Duration)
+		if c.Source != "" {
+			fmt.Fprintf(&b, ", from %s", c.Source)
+		}
+		b.WriteString("\n")
+		if c.Error != "" {
+			fmt.Fprintf(&b, "\n**Error:** %s\n", c.Error)
+		}
+		if c.Stdout != "" {
+			b.WriteString("\n")
+			writeMarkdownBlock(&b, c.Stdout)
+		}
+		if c.Stderr != "" {
+			b.WriteString("\nstderr:\n\n")
+			writeMarkdownBlock(&b, c.Stderr)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMarkdownBlock writes text as a fenced code block whose fence is longer
+// than any backtick run inside text.
+func writeMarkdownBlock(b *strings.Builder, text string) {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	fmt.Fprintf(b, "%stext\n%s\n%s\n", fence, strings.TrimRight(text, "\n"), fence)
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"host": hostLine,
+	"time": func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 1100px; margin: 2em auto; padding: 0 1em; color: #1f2328; }
+h1 { margin-bottom: 0.2em; }
+dl.meta { display: grid; grid-template-columns: max-content auto; gap: 0.2em 1em; color: #57606a; }
+dl.meta dt { font-weight: 600; }
+dl.meta dd { margin: 0; }
+pre { background: #f6f8fa; padding: 0.8em; overflow-x: auto; font-size: 0.85em; line-height: 1.35; }
+pre.summary { white-space: pre-wrap; background: none; font-family: inherit; font-size: 1em; padding: 0; }
+details { border: 1px solid #d0d7de; border-radius: 6px; margin: 0.5em 0; padding: 0.3em 0.8em; }
+summary { cursor: pointer; font-weight: 600; }
+summary .desc, summary .timing { font-weight: normal; color: #57606a; }
+.ok { color: #1a7f37; }
+.fail { color: #cf222e; }
+.timeout { color: #9a6700; }
+.error { color: #cf222e; }
+ul.findings li.critical strong { color: #cf222e; }
+ul.findings li.warning strong { color: #9a6700; }
+ul.findings li.info strong { color: #57606a; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<dl class="meta">
+{{- with .Report.Playbook.ID}}<dt>Playbook</dt><dd><code>{{.}}</code></dd>{{end}}
+<dt>Started</dt><dd>{{time .Report.StartedAt}}</dd>
+<dt>Duration</dt><dd>{{printf "%.1f" .Report.Duration}} s</dd>
+<dt>Host</dt><dd>{{host .Report.Host}}</dd>
+{{- with .Report.ToolboxURL}}<dt>Toolbox</dt><dd>{{.}}</dd>{{end}}
+</dl>
+{{with .Report.Error}}<p class="error"><strong>Error:</strong> {{.}}</p>{{end}}
+{{- with .Report.Findings}}
+<h2>Findings</h2>
+<ul class="findings">
+{{- range .}}
+<li class="{{.Severity}}"><strong>{{.Severity}}</strong> {{.Message}} <code>{{.Command}}</code></li>
+{{- end}}
+</ul>
+{{- end}}
+<h2>AI Summary</h2>
+{{- if .Report.Summary.Text}}
+<pre class="summary">{{.Report.Summary.Text}}</pre>
+{{- else if .Report.Summary.Error}}
+<p class="error">LLM error: {{.Report.Summary.Error}}</p>
+{{- else if .Report.Summary.Skipped}}
+<p><em>Skipped: {{.Report.Summary.Skipped}}</em></p>
+{{- else}}
+<p><em>Not available.</em></p>
+{{- end}}
+<h2>Commands</h2>
+{{range .Report.Commands}}
+<details{{if ne .Status "success"}} open{{end}}>
+<summary>{{if eq .Status "success"}}<span class="ok">✓</span>{{else if eq .Status "timed_out"}}<span class="timeout">⧗</span>{{else}}<span class="fail">✗</span>{{end}} <code>{{.Command}}</code>{{with .Description}} <span class="desc">— {{.}}</span>{{end}} <span class="timing">({{printf "%.2f" .Duration}} s, exit code {{.ExitCode}}{{with .Source}}, from {{.}}{{end}})</span></summary>
+{{- with .Error}}
+<p class="error"><strong>Error:</strong> {{.}}</p>
+{{- end}}
+{{- with .Stdout}}
+<pre>{{.}}</pre>
+{{- end}}
+{{- with .Stderr}}
+<p>stderr:</p>
+<pre>{{.}}</pre>
+{{- end}}
+</details>
+{{- end}}
+</body>
+</html>
+`))
+
+// writeHTMLReport renders report as a single self-contained HTML page with a
+// collapsible section per command.
+func writeHTMLReport(w io.Writer, report *RunReport) error {
+	return htmlReportTemplate.Execute(w, struct {
+		Title  string
+		Report *RunReport
+	}{reportTitle(report), report})
+}
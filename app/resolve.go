@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+
+	"gradient-engineer/binindex"
+)
+
+// ResolvePolicy decides where the binary of a playbook command is looked up.
+type ResolvePolicy string
+
+const (
+	ResolveToolboxOnly     ResolvePolicy = "toolbox-only"      // Only binaries shipped in the toolbox
+	ResolveToolboxThenHost ResolvePolicy = "toolbox-then-host" // Toolbox first, then the host PATH
+	ResolveHostOnly        ResolvePolicy = "host-only"         // Only the host PATH
+)
+
+// Command sources reported in DiagnosticCommand.Source.
+const (
+	sourceToolbox = "toolbox"
+	sourceHost    = "host"
+)
+
+// ParseResolvePolicy validates a policy name. An empty name yields an empty
+// policy, meaning "use the default".
+func ParseResolvePolicy(s string) (ResolvePolicy, error) {
+	switch p := ResolvePolicy(s); p {
+	case "", ResolveToolboxOnly, ResolveToolboxThenHost, ResolveHostOnly:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown resolve policy %q (want %s, %s or %s)", s, ResolveToolboxOnly, ResolveToolboxThenHost, ResolveHostOnly)
+	}
+}
+
+// defaultResolvePolicy is used when neither the run nor the command picks a
+// policy. macOS toolboxes ship no binaries, and local playbooks may not have
+// a toolbox at all, so both fall back to the host.
+func (t *Toolbox) defaultResolvePolicy() ResolvePolicy {
+	if t.Resolve != "" {
+		return t.Resolve
+	}
+	if runtime.GOOS == "darwin" || t.PlaybookFile != "" {
+		return ResolveToolboxThenHost
+	}
+	return ResolveToolboxOnly
+}
+
+// loadBinIndex reads the binary index of the toolbox at toolboxPath. It
+// returns nil for toolboxes built before the index existed, or when there is
+// no toolbox at all.
+func loadBinIndex(toolboxPath string) (*binindex.Index, error) {
+	idx, err := binindex.Load(filepath.Join(toolboxPath, binindex.FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return idx, err
+}
+
+// findInStore locates binName in the toolbox at toolboxPath, restricted to
+// the store paths of pkg when it is not empty. It returns an empty string
+// when the toolbox does not ship it. Without an index the store is scanned
+// for */bin and */sbin.
+func findInStore(toolboxPath string, idx *binindex.Index, binName, pkg string) string {
+	if idx != nil {
+		e, ok := idx.Lookup(binName, pkg)
+		if !ok {
+			return ""
+		}
+		return filepath.Join(toolboxPath, filepath.FromSlash(e.Path))
+	}
+
+	storeDir := filepath.Join(toolboxPath, "nix", "store")
+	entries, err := os.ReadDir(storeDir)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if !e.IsDir() || (pkg != "" && !binindex.PackageOf(e.Name(), pkg)) {
+			continue
+		}
+		for _, dir := range []string{"bin", "sbin"} {
+			candidate := filepath.Join(storeDir, e.Name(), dir, binName)
+			if st, err := os.Stat(candidate); err == nil && !st.IsDir() && (st.Mode()&0o111 != 0) {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+// storeBinDirs lists the */bin and */sbin directories of the toolbox, for
+// use as PATH of toolbox shells. Directories of pkg come first.
+func storeBinDirs(toolboxPath string, idx *binindex.Index, pkg string) []string {
+	var dirs, preferred []string
+	add := func(dir, owner string) {
+		if pkg != "" && owner == pkg {
+			preferred = append(preferred, dir)
+		} else {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	if idx != nil {
+		byDir := map[string]string{}
+		for _, list := range idx.Binaries {
+			for _, e := range list {
+				byDir[path.Dir(e.Path)] = e.Package
+			}
+		}
+		for _, d := range idx.Dirs() {
+			add(filepath.Join(toolboxPath, filepath.FromSlash(d)), byDir[d])
+		}
+		return append(preferred, dirs...)
+	}
+
+	storeDir := filepath.Join(toolboxPath, "nix", "store")
+	entries, err := os.ReadDir(storeDir)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		owner := ""
+		if pkg != "" && binindex.PackageOf(e.Name(), pkg) {
+			owner = pkg
+		}
+		for _, dir := range []string{"bin", "sbin"} {
+			p := filepath.Join(storeDir, e.Name(), dir)
+			if st, err := os.Stat(p); err == nil && st.IsDir() {
+				add(p, owner)
+			}
+		}
+	}
+	return append(preferred, dirs...)
+}
+
+// findOnHost locates binName on the host PATH.
+func findOnHost(binName string) string {
+	p, err := exec.LookPath(binName)
+	if err != nil {
+		return ""
+	}
+	return p
+}
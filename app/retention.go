@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Which part of a long output is kept for the UI and the summarizer.
+const (
+	keepHead = "head"
+	keepTail = "tail"
+	keepBoth = "both"
+)
+
+// Defaults used when a playbook command does not set its own limits.
+const (
+	defaultMaxLines = 100
+	defaultMaxBytes = 32 * 1024
+)
+
+// OutputRetention bounds the part of a command's output that is shown in the
+// UI and sent to the summarizer. The full output is kept on disk regardless.
+type OutputRetention struct {
+	MaxLines int    // 0 means unlimited
+	MaxBytes int    // 0 means unlimited
+	Keep     string // keepHead, keepTail or keepBoth
+}
+
+// newOutputRetention validates the playbook settings and fills in defaults.
+// Negative limits disable the respective bound.
+func newOutputRetention(maxLines, maxBytes int, keep string) (OutputRetention, error) {
+	r := OutputRetention{MaxLines: maxLines, MaxBytes: maxBytes, Keep: keep}
+	switch {
+	case r.MaxLines == 0:
+		r.MaxLines = defaultMaxLines
+	case r.MaxLines < 0:
+		r.MaxLines = 0
+	}
+	switch {
+	case r.MaxBytes == 0:
+		r.MaxBytes = defaultMaxBytes
+	case r.MaxBytes < 0:
+		r.MaxBytes = 0
+	}
+	switch r.Keep {
+	case "":
+		r.Keep = keepTail
+	case keepHead, keepTail, keepBoth:
+	default:
+		return r, fmt.Errorf("unknown keep %q (want %s, %s or %s)", keep, keepHead, keepTail, keepBoth)
+	}
+	return r, nil
+}
+
+// Trim applies the line limit and then the byte limit to s. It reports
+// whether anything was cut.
+func (r OutputRetention) Trim(s string) (string, bool) {
+	lines, cutLines := r.trimLines(s)
+	out, cutBytes := r.trimBytes(lines)
+	return out, cutLines || cutBytes
+}
+
+func (r OutputRetention) trimLines(s string) (string, bool) {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if r.MaxLines <= 0 || len(lines) <= r.MaxLines {
+		return s, false
+	}
+	omitted := len(lines) - r.MaxLines
+	switch r.Keep {
+	case keepHead:
+		return strings.Join(lines[:r.MaxLines], "\n") + fmt.Sprintf("\n[... %d more lines omitted ...]\n", omitted), true
+	case keepBoth:
+		head := (r.MaxLines + 1) / 2
+		tail := r.MaxLines - head
+		return strings.Join(lines[:head], "\n") +
+			fmt.Sprintf("\n[... %d lines omitted ...]\n", omitted) +
+			strings.Join(lines[len(lines)-tail:], "\n") + "\n", true
+	default:
+		return fmt.Sprintf("[... %d earlier lines omitted ...]\n", omitted) + strings.Join(lines[omitted:], "\n") + "\n", true
+	}
+}
+
+func (r OutputRetention) trimBytes(s string) (string, bool) {
+	if r.MaxBytes <= 0 || len(s) <= r.MaxBytes {
+		return s, false
+	}
+	omitted := len(s) - r.MaxBytes
+	switch r.Keep {
+	case keepHead:
+		return cutHead(s, r.MaxBytes) + fmt.Sprintf("\n[... %d more bytes omitted ...]\n", omitted), true
+	case keepBoth:
+		head := r.MaxBytes / 2
+		return cutHead(s, head) +
+			fmt.Sprintf("\n[... %d bytes omitted ...]\n", omitted) +
+			cutTail(s, r.MaxBytes-head), true
+	default:
+		return fmt.Sprintf("[... %d earlier bytes omitted ...]\n", omitted) + cutTail(s, r.MaxBytes), true
+	}
+}
+
+// cutHead returns at most n leading bytes of s without splitting a rune.
+func cutHead(s string, n int) string {
+	for n > 0 && n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// cutTail returns at most n trailing bytes of s without splitting a rune.
+func cutTail(s string, n int) string {
+	i := len(s) - n
+	for i < len(s) && i > 0 && !utf8.RuneStart(s[i]) {
+		i++
+	}
+	return s[i:]
+}
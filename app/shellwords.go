@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// splitCommand parses a playbook command line the way a POSIX shell would
+// tokenize a simple command: single quotes are literal, double quotes allow
+// \" \\ \$ and \` escapes, a backslash outside quotes escapes the next
+// character, and leading NAME=value words are returned as environment
+// assignments. Pipes, redirects, substitutions and other operators are
+// rejected, as are unquoted glob and tilde characters; commands that need
+// them must set shell: true.
+func splitCommand(s string) (env, argv []string, err error) {
+	var (
+		word    strings.Builder
+		inWord  bool
+		inQuote rune
+		quoteAt = -1 // offset in word of its first quoted or escaped part
+	)
+	flush := func() {
+		if !inWord {
+			return
+		}
+		w := word.String()
+		// Only an unquoted NAME= prefix makes an assignment, as in FOO="a b"
+		if len(argv) == 0 && isAssignment(w) && (quoteAt < 0 || strings.IndexByte(w, '=') < quoteAt) {
+			env = append(env, w)
+		} else {
+			argv = append(argv, w)
+		}
+		word.Reset()
+		inWord, quoteAt = false, -1
+	}
+	markQuoted := func() {
+		if quoteAt < 0 {
+			quoteAt = word.Len()
+		}
+		inWord = true
+	}
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch inQuote {
+		case '\'':
+			if r == '\'' {
+				inQuote = 0
+			} else {
+				word.WriteRune(r)
+			}
+			continue
+		case '"':
+			switch r {
+			case '"':
+				inQuote = 0
+			case '\\':
+				if i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] != '\n' {
+						word.WriteRune(runes[i])
+					}
+				} else {
+					word.WriteRune(r)
+				}
+			case '$', '`':
+				return nil, nil, fmt.Errorf("substitution %q in %q requires shell: true", r, s)
+			default:
+				word.WriteRune(r)
+			}
+			continue
+		}
+
+		switch r {
+		case ' ', '\t', '\n':
+			flush()
+		case '\'', '"':
+			inQuote = r
+			markQuoted()
+		case '\\':
+			if i+1 >= len(runes) {
+				return nil, nil, fmt.Errorf("trailing backslash in %q", s)
+			}
+			i++
+			if runes[i] != '\n' {
+				markQuoted()
+				word.WriteRune(runes[i])
+			}
+		case '|', '&', ';', '<', '>', '(', ')', '$', '`':
+			return nil, nil, fmt.Errorf("shell operator %q in %q requires shell: true", r, s)
+		case '*', '?', '[', '~':
+			// A shell would expand these instead of passing them through
+			return nil, nil, fmt.Errorf("expansion %q in %q requires shell: true", r, s)
+		case '#':
+			if !inWord {
+				return nil, nil, fmt.Errorf("comment in %q requires shell: true", s)
+			}
+			word.WriteRune(r)
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inQuote != 0 {
+		return nil, nil, fmt.Errorf("unterminated %c quote in %q", inQuote, s)
+	}
+	flush()
+	if len(argv) == 0 {
+		return nil, nil, fmt.Errorf("command '%s' is empty", s)
+	}
+	return env, argv, nil
+}
+
+// shellProgram returns the name of the first program a shell command line
+// runs, skipping leading NAME=value assignments, e.g. "vmstat" for
+// "LC_ALL=C vmstat 1 | tail -n +3".
+func shellProgram(command string) string {
+	for _, w := range strings.Fields(command) {
+		if !isAssignment(w) {
+			return filepath.Base(w)
+		}
+	}
+	return ""
+}
+
+// isAssignment reports whether w has the form NAME=value with a valid shell
+// variable name.
+func isAssignment(w string) bool {
+	name, _, ok := strings.Cut(w, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// quoteArg quotes s for display so that splitCommand would read it back as a
+// single word.
+func quoteArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\|&;<>()$`#*?[~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		in      string
+		env     []string
+		argv    []string
+		wantErr string
+	}{
+		{in: "vmstat 1 5", argv: []string{"vmstat", "1", "5"}},
+		{in: "  uptime\t", argv: []string{"uptime"}},
+		{in: `echo 'a b' "c d"`, argv: []string{"echo", "a b", "c d"}},
+		{in: `echo 'it'\''s'`, argv: []string{"echo", "it's"}},
+		{in: `echo "say \"hi\" \\ \$HOME \x"`, argv: []string{"echo", `say "hi" \ $HOME \x`}},
+		{in: `echo a\ b \$x`, argv: []string{"echo", "a b", "$x"}},
+		{in: "echo a\\\nb", argv: []string{"echo", "ab"}},
+		{in: `echo ''`, argv: []string{"echo", ""}},
+		{in: "echo a#b", argv: []string{"echo", "a#b"}},
+		{in: "S_TIME_FORMAT=ISO LC_ALL=C mpstat 1 1", env: []string{"S_TIME_FORMAT=ISO", "LC_ALL=C"}, argv: []string{"mpstat", "1", "1"}},
+		{in: `FOO="a b" env`, env: []string{"FOO=a b"}, argv: []string{"env"}},
+		{in: `"FOO=a" env`, argv: []string{"FOO=a", "env"}},
+		{in: "env FOO=a", argv: []string{"env", "FOO=a"}},
+		{in: "1FOO=a env", argv: []string{"1FOO=a", "env"}},
+		{in: `ls '*.log' "a?" \[x\] \~`, argv: []string{"ls", "*.log", "a?", "[x]", "~"}},
+
+		{in: "", wantErr: "is empty"},
+		{in: "FOO=a", wantErr: "is empty"},
+		{in: "ps aux | head", wantErr: "requires shell: true"},
+		{in: "dmesg > out", wantErr: "requires shell: true"},
+		{in: "a && b", wantErr: "requires shell: true"},
+		{in: "a; b", wantErr: "requires shell: true"},
+		{in: "echo $HOME", wantErr: "requires shell: true"},
+		{in: "echo `id`", wantErr: "requires shell: true"},
+		{in: `echo "$(id)"`, wantErr: "requires shell: true"},
+		{in: "echo (x)", wantErr: "requires shell: true"},
+		{in: "uptime # load", wantErr: "requires shell: true"},
+		{in: "ls /var/log/*.log", wantErr: "requires shell: true"},
+		{in: "ls file?", wantErr: "requires shell: true"},
+		{in: "ls [ab]", wantErr: "requires shell: true"},
+		{in: "ls ~", wantErr: "requires shell: true"},
+		{in: "HOME=~/x env", wantErr: "requires shell: true"},
+		{in: `echo 'open`, wantErr: "unterminated ' quote"},
+		{in: `echo "open`, wantErr: `unterminated " quote`},
+		{in: `echo \`, wantErr: "trailing backslash"},
+	}
+	for _, tt := range tests {
+		env, argv, err := splitCommand(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("splitCommand(%q) err = %v; want one containing %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitCommand(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(env, tt.env) || !reflect.DeepEqual(argv, tt.argv) {
+			t.Errorf("splitCommand(%q) = %q, %q; want %q, %q", tt.in, env, argv, tt.env, tt.argv)
+		}
+	}
+}
+
+func TestShellProgram(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"dmesg | tail -n 20", "dmesg"},
+		{"LC_ALL=C vmstat 1 5 | tail -n +3", "vmstat"},
+		{"S_TIME_FORMAT=ISO LC_ALL=C mpstat -P ALL 1 1", "mpstat"},
+		{"/usr/bin/free -m", "free"},
+		{"FOO=a", ""},
+	}
+	for _, tt := range tests {
+		if got := shellProgram(tt.in); got != tt.want {
+			t.Errorf("shellProgram(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteArgRoundTrip(t *testing.T) {
+	args := []string{
+		"vmstat", "-x", "", "a b", "tab\there", "line\nbreak", "it's", `"quoted"`, `back\slash`,
+		"a|b", "a&b", "a;b", "<in>", "(x)", "$HOME", "`id`", "#comment", "*.log", "file?", "[ab]", "~", "~user",
+		"FOO=bar", "'", `'\''`, "ünïcode",
+	}
+	for _, a := range args {
+		q := quoteArg(a)
+		// A leading word keeps an argument that looks like an assignment out
+		// of the environment
+		env, argv, err := splitCommand("cmd " + q)
+		if err != nil {
+			t.Errorf("quoteArg(%q) = %s does not parse: %v", a, q, err)
+			continue
+		}
+		if len(env) != 0 || len(argv) != 2 || argv[1] != a {
+			t.Errorf("quoteArg(%q) = %s parses as %q, %q", a, q, env, argv)
+		}
+	}
+}
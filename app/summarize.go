@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
-	"embed"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"slices"
 	"strings"
 
 	"gradient-engineer/playbook"
+	"gradient-engineer/redact"
+	"gradient-engineer/rules"
 
 	anthropic "github.com/anthropics/anthropic-sdk-go"
 	anthopt "github.com/anthropics/anthropic-sdk-go/option"
@@ -16,11 +20,12 @@ import (
 	openaiopt "github.com/openai/openai-go/option"
 )
 
-// SummaryCommand represents a command's description and its captured output
+// SummaryCommand represents a command's description and its captured result
 // used for generating an LLM summary.
 type SummaryCommand struct {
 	Description *playbook.PlaybookCommand
-	Output      string
+	Result      *CommandResult // nil if the command could not be started
+	Err         error
 }
 
 // Summarizer encapsulates LLM client configuration used for summarization.
@@ -29,150 +34,442 @@ type Summarizer struct {
 	openaiClient    openai.Client
 	anthropicClient anthropic.Client
 	model           string
-	models          []string // fallback models
+	models          []string  // fallback models
+	local           *localLLM // Set for provider "local"
+	endpoint        string    // Base URL requests go to
+	redactor        *redact.Redactor
+	maxTokens       int // 0 for the provider's default
+	temperature     *float64
+	public          bool // Free public models through the shared OpenRouter key
 	disabled        bool
+	publicAvailable bool  // Disabled, but --allow-public-llm would enable the shared key
+	err             error // Why the configured provider cannot be used; returned by Stream
 }
 
-// NewSummarizer constructs a Summarizer with provider selection based on env vars.
+// publicLLM says whether the summary may fall back to free public models
+// through the shared OpenRouter key when no other provider is configured.
+type publicLLM int
+
+const (
+	publicLLMAsk      publicLLM = iota // Not consented to; the summary is skipped
+	publicLLMAllowed                   // --allow-public-llm
+	publicLLMDisabled                  // --no-public-llm
+)
+
+// Providers that can be selected explicitly.
+var llmProviders = []string{"anthropic", "openai", "openrouter", "local"}
+
+// Default model per provider.
+const (
+	defaultAnthropicModel  = "claude-sonnet-4-0"
+	defaultOpenAIModel     = "gpt-4.1"
+	defaultOpenRouterModel = "openai/gpt-4.1"
+	openRouterBaseURL      = "https://openrouter.ai/api/v1"
+)
+
+// overrideLLM returns base with every field set in over replacing it. A
+// different provider also discards the model and endpoint chosen for the old
+// one.
+func overrideLLM(base, over playbook.LLMConfig) playbook.LLMConfig {
+	if over.Provider != "" && over.Provider != base.Provider {
+		base = playbook.LLMConfig{Provider: over.Provider, MaxTokens: base.MaxTokens, Temperature: base.Temperature}
+	}
+	if over.Model != "" {
+		base.Model = over.Model
+	}
+	if over.BaseURL != "" {
+		base.BaseURL = over.BaseURL
+	}
+	if over.MaxTokens != 0 {
+		base.MaxTokens = over.MaxTokens
+	}
+	if over.Temperature != nil {
+		base.Temperature = over.Temperature
+	}
+	return base
+}
+
+// checkLLMProvider reports an error for a provider name NewSummarizer does
+// not know.
+func checkLLMProvider(provider string) error {
+	if provider == "" || slices.Contains(llmProviders, provider) {
+		return nil
+	}
+	return fmt.Errorf("unknown LLM provider %q (want %s)", provider, strings.Join(llmProviders, ", "))
+}
+
+// llmLayers holds the LLM settings known before the playbook is loaded. The
+// playbook's llm: block is applied between the two.
+type llmLayers struct {
+	Config playbook.LLMConfig // From config.yaml
+	Flags  playbook.LLMConfig // From the command line
+	Public publicLLM          // Consent to the shared key
+	Redact playbook.Redaction // From config.yaml; the playbook's redact: patterns are added
+}
+
+// resolve returns the settings in effect for pb, which may be nil. The
+// playbook's base_url is ignored.
+func (l llmLayers) resolve(pb *playbook.PlaybookConfig) playbook.LLMConfig {
+	cfg := l.Config
+	if pb != nil {
+		// The endpoint would receive the API keys from the environment, so
+		// only the user may choose it
+		over := pb.LLM
+		over.BaseURL = ""
+		cfg = overrideLLM(cfg, over)
+	}
+	return overrideLLM(cfg, l.Flags)
+}
+
+// summarizer creates the Summarizer for pb, masking command output with the
+// redaction settings of the config file and the playbook.
+func (l llmLayers) summarizer(pb *playbook.PlaybookConfig) *Summarizer {
+	s := NewSummarizer(l.resolve(pb), l.Public)
+	redactions := []playbook.Redaction{l.Redact}
+	if pb != nil {
+		// A playbook may mask more, never less
+		redactions = append(redactions, playbook.Redaction{Patterns: pb.Redact.Patterns})
+	}
+	hostname, _ := os.Hostname()
+	red, err := redact.New(hostname, redactions...)
+	if err != nil {
+		// Nothing may be sent unredacted
+		s.err = err
+	}
+	s.redactor = red
+	return s
+}
+
+// NewSummarizer constructs a Summarizer for opts. With an explicit provider
+// its API key must be set in the environment. Otherwise the provider is
+// selected based on env vars.
 // Priority:
-// - If ANTHROPIC_API_KEY is set, use Anthropic (claude-sonnet-4-0)
+// - If LOCAL_LLM_BASE_URL (or OLLAMA_HOST) is set, use that local server
+// - Else if ANTHROPIC_API_KEY is set, use Anthropic (claude-sonnet-4-0)
 // - Else if OPENROUTER_API_KEY is set, use OpenRouter base and that key
 // - Else if OPENAI_API_KEY starts with "sk-or-v1-", treat it as an OpenRouter key
 // - Else if OPENAI_API_KEY is set, use default OpenAI base and that key
-// - Else fallback to fk
-// Base URL can be overridden via OPENAI_BASE_URL for OpenAI/OpenRouter.
-func NewSummarizer() *Summarizer {
-	baseOverride := os.Getenv("OPENAI_BASE_URL")
-	openRouterKey := os.Getenv("OPENROUTER_API_KEY")
-	fk := getFK()
-	openAIKey := os.Getenv("OPENAI_API_KEY")
-	anthropicKey := os.Getenv("ANTHROPIC_API_KEY")
+// - Else if a local server is listening on a well-known port, use it
+// - Else if public is publicLLMAllowed, fallback to fk
+// Base URL can be overridden via opts or OPENAI_BASE_URL for OpenAI/OpenRouter.
+func NewSummarizer(opts playbook.LLMConfig, public publicLLM) *Summarizer {
+	s := newSummarizer(opts, public)
+	s.maxTokens = opts.MaxTokens
+	s.temperature = opts.Temperature
+	return s
+}
+
+func newSummarizer(opts playbook.LLMConfig, public publicLLM) *Summarizer {
+	openRouterKey := strings.TrimSpace(os.Getenv("OPENROUTER_API_KEY"))
+	openAIKey := strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
+	anthropicKey := strings.TrimSpace(os.Getenv("ANTHROPIC_API_KEY"))
 
 	// Heuristic: detect OpenRouter key provided via OPENAI_API_KEY
 	if openRouterKey == "" && strings.HasPrefix(openAIKey, "sk-or-v1-") {
 		openRouterKey = openAIKey
 	}
 
-	// If no key is provided for any provider, mark summarizer as disabled.
-	if strings.TrimSpace(anthropicKey) == "" && strings.TrimSpace(openRouterKey) == "" && strings.TrimSpace(openAIKey) == "" && strings.TrimSpace(fk) == "" {
-		return &Summarizer{
-			provider: "none",
-			model:    "",
-			disabled: true,
+	missing := func(env string) *Summarizer {
+		return &Summarizer{provider: opts.Provider, err: fmt.Errorf("%s is required for LLM provider %s", env, opts.Provider)}
+	}
+	switch opts.Provider {
+	case "anthropic":
+		if anthropicKey == "" {
+			return missing("ANTHROPIC_API_KEY")
+		}
+		return newAnthropicSummarizer(anthropicKey, opts)
+	case "openai":
+		if openAIKey == "" {
+			return missing("OPENAI_API_KEY")
 		}
+		return newOpenAISummarizer("openai", openAIKey, false, opts)
+	case "openrouter":
+		if openRouterKey == "" {
+			return missing("OPENROUTER_API_KEY")
+		}
+		return newOpenAISummarizer("openrouter", openRouterKey, false, opts)
+	case "local":
+		l, err := findLocal(opts.BaseURL, opts.Model)
+		if err != nil {
+			return &Summarizer{provider: "local", err: err}
+		}
+		return newLocalSummarizer(l)
+	case "":
+	default:
+		return &Summarizer{provider: opts.Provider, err: checkLLMProvider(opts.Provider)}
+	}
+
+	// An explicitly configured local server wins so that nothing leaves the
+	// machine even when cloud keys are present in the environment
+	if l, err := localFromEnv(opts.Model); err != nil {
+		return &Summarizer{provider: "local", err: err}
+	} else if l != nil {
+		return newLocalSummarizer(l)
+	}
+
+	switch {
+	case anthropicKey != "":
+		return newAnthropicSummarizer(anthropicKey, opts)
+	case openRouterKey != "":
+		return newOpenAISummarizer("openrouter", openRouterKey, false, opts)
+	case openAIKey != "":
+		return newOpenAISummarizer("openai", openAIKey, false, opts)
+	}
+	if l := detectLocal(opts.Model); l != nil {
+		return newLocalSummarizer(l)
 	}
 
-	// Anthropic has highest priority if explicitly provided
-	if strings.TrimSpace(anthropicKey) != "" {
-		cli := anthropic.NewClient(anthopt.WithAPIKey(anthropicKey))
+	// If no key is provided for any provider, mark summarizer as disabled.
+	// The shared key is only used with consent.
+	fk := ""
+	if public != publicLLMDisabled {
+		fk = strings.TrimSpace(getFK())
+	}
+	if fk == "" || public != publicLLMAllowed {
 		return &Summarizer{
-			provider:        "anthropic",
-			anthropicClient: cli,
-			model:           "claude-sonnet-4-0",
-			disabled:        false,
+			provider:        "none",
+			model:           "",
+			disabled:        true,
+			publicAvailable: fk != "",
 		}
 	}
+	s := newOpenAISummarizer("openrouter", fk, true, opts)
+	s.public = true
+	return s
+}
 
-	usingOpenRouter := openRouterKey != ""
-	usingFK := openRouterKey == "" && openAIKey == ""
+// newAnthropicSummarizer uses the Anthropic Messages API.
+func newAnthropicSummarizer(key string, opts playbook.LLMConfig) *Summarizer {
+	reqOpts := []anthopt.RequestOption{anthopt.WithAPIKey(key)}
+	if opts.BaseURL != "" {
+		reqOpts = append(reqOpts, anthopt.WithBaseURL(opts.BaseURL))
+	}
+	model := defaultAnthropicModel
+	if opts.Model != "" {
+		model = opts.Model
+	}
+	endpoint := opts.BaseURL
+	if endpoint == "" {
+		endpoint = "https://api.anthropic.com"
+	}
+	return &Summarizer{
+		provider:        "anthropic",
+		anthropicClient: anthropic.NewClient(reqOpts...),
+		model:           model,
+		endpoint:        endpoint,
+		disabled:        false,
+	}
+}
 
+// newOpenAISummarizer uses the chat completions API of OpenAI or, for
+// provider "openrouter", OpenRouter. free selects the free OpenRouter models
+// used with the fallback key.
+func newOpenAISummarizer(provider, key string, free bool, opts playbook.LLMConfig) *Summarizer {
 	// Determine base URL for OpenAI/OpenRouter
-	baseURL := ""
-	if baseOverride != "" {
-		baseURL = baseOverride
-	} else if usingOpenRouter || usingFK {
-		baseURL = "https://openrouter.ai/api/v1"
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = os.Getenv("OPENAI_BASE_URL")
+	}
+	if baseURL == "" && provider == "openrouter" {
+		baseURL = openRouterBaseURL
 	}
 
 	// Build OpenAI client options
-	var opts []openaiopt.RequestOption
+	reqOpts := []openaiopt.RequestOption{openaiopt.WithAPIKey(key)}
 	if baseURL != "" {
-		opts = append(opts, openaiopt.WithBaseURL(baseURL))
+		reqOpts = append(reqOpts, openaiopt.WithBaseURL(baseURL))
+	} else {
+		baseURL = "https://api.openai.com/v1"
 	}
-	if usingOpenRouter || usingFK {
-		if usingOpenRouter {
-			opts = append(opts, openaiopt.WithAPIKey(openRouterKey))
-		} else {
-			opts = append(opts, openaiopt.WithAPIKey(fk))
-		}
+	if provider == "openrouter" {
 		// OpenRouter attribution headers
-		opts = append(opts,
+		reqOpts = append(reqOpts,
 			openaiopt.WithHeader("X-Title", "gradient-engineer"),
 			openaiopt.WithHeader("HTTP-Referer", "https://gradient.engineer"),
 		)
-	} else if openAIKey != "" {
-		opts = append(opts, openaiopt.WithAPIKey(openAIKey))
 	}
 
 	// Choose a model slug compatible with provider
-	model := "gpt-4.1"
+	model := defaultOpenAIModel
 	models := []string{}
-	if usingOpenRouter {
-		model = "openai/gpt-4.1"
-	} else if usingFK {
+	if free {
 		model = "deepseek/deepseek-chat-v3.1:free"
 		models = []string{"deepseek/deepseek-chat-v3-0324:free", "moonshotai/kimi-k2:free", "meta-llama/llama-3.3-70b-instruct:free"}
+	} else if provider == "openrouter" {
+		model = defaultOpenRouterModel
+	}
+	if opts.Model != "" {
+		model = opts.Model
+		models = nil
 	}
-
-	cli := openai.NewClient(opts...)
 
 	return &Summarizer{
-		provider:     "openai",
-		openaiClient: cli,
+		provider:     provider,
+		openaiClient: openai.NewClient(reqOpts...),
 		model:        model,
 		models:       models,
+		endpoint:     baseURL,
 		disabled:     false,
 	}
 }
 
+// newLocalSummarizer talks to a local server. OpenAI-compatible servers go
+// through the OpenAI client; Ollama's native API is spoken directly.
+func newLocalSummarizer(l *localLLM) *Summarizer {
+	s := &Summarizer{provider: "local", local: l, model: l.Model, endpoint: l.BaseURL}
+	if l.API == localAPIOpenAI {
+		// Set a key explicitly so OPENAI_API_KEY is never sent to the local server
+		key := os.Getenv("LOCAL_LLM_API_KEY")
+		if key == "" {
+			key = "local"
+		}
+		s.openaiClient = openai.NewClient(openaiopt.WithBaseURL(l.BaseURL), openaiopt.WithAPIKey(key))
+	}
+	return s
+}
+
+// Label names the provider and model in effect, e.g. "anthropic,
+// claude-sonnet-4-0", for display.
+func (s *Summarizer) Label() string {
+	switch {
+	case s.disabled:
+		return ""
+	case s.local != nil:
+		return fmt.Sprintf("local, %s at %s", s.model, strings.TrimSuffix(s.local.BaseURL, "/v1"))
+	case s.model == "":
+		return s.provider
+	default:
+		return s.provider + ", " + s.model
+	}
+}
+
+// Remote reports whether the summary sends command output off this machine.
+// Local providers on a loopback address do not.
+func (s *Summarizer) Remote() bool {
+	if s.disabled || s.err != nil {
+		return false
+	}
+	u, err := url.Parse(s.endpoint)
+	if err != nil {
+		return true
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
+
+// DataNotice tells the user where command output is sent, or returns "" when
+// it stays on this machine.
+func (s *Summarizer) DataNotice() string {
+	if !s.Remote() {
+		return ""
+	}
+	host := s.endpoint
+	if u, err := url.Parse(s.endpoint); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	if s.public {
+		return fmt.Sprintf("Command output is sent to free public models on OpenRouter (%s) using a shared key; their providers may log and train on it.", s.model)
+	}
+	return fmt.Sprintf("Command output is sent to %s (%s) at %s.", s.provider, s.model, host)
+}
+
+// DisabledNotice explains why no summary is generated and how to get one.
+func (s *Summarizer) DisabledNotice() string {
+	msg := "No API key provided; skipping AI summary.\nSet the API key with OPENAI_API_KEY, OPENROUTER_API_KEY, or ANTHROPIC_API_KEY, or run a local model server."
+	if s.publicAvailable {
+		msg += "\nPass --allow-public-llm to use free public models through OpenRouter with a shared key instead."
+	}
+	return msg
+}
+
 // Summarize generates a summary given a system prompt and a list of command
 // descriptions paired with their outputs. The systemPrompt is passed as a
-// system message, and the concatenated command outputs are passed as a user
-// message.
-func (s *Summarizer) Summarize(systemPrompt string, commands []SummaryCommand) (string, error) {
-	ctx := context.Background()
+// system message, and the concatenated command outputs followed by the rule
+// findings are passed as a user message.
+func (s *Summarizer) Summarize(systemPrompt string, commands []SummaryCommand, findings []rules.Finding) (string, error) {
+	return s.Stream(context.Background(), systemPrompt, commands, findings, nil)
+}
 
-	var b strings.Builder
-	for i, c := range commands {
-		if strings.TrimSpace(c.Output) == "" {
-			continue
+// UserMessage returns the user message exactly as it is sent: the command
+// outputs and findings with secrets and personal data replaced by
+// placeholders.
+func (s *Summarizer) UserMessage(commands []SummaryCommand, findings []rules.Finding) string {
+	return s.redactor.Redact(summaryPrompt(commands, findings))
+}
+
+// Restore puts the values hidden by UserMessage back into text written by
+// the model.
+func (s *Summarizer) Restore(text string) string {
+	return s.redactor.Restore(text)
+}
+
+// Stream generates the summary like Summarize, calling onDelta with each
+// piece of text as the model produces it. It returns the full text once the
+// response is complete, or the error that ended it, including the
+// cancellation of ctx. The deltas still contain placeholders; the returned
+// text has them restored.
+func (s *Summarizer) Stream(ctx context.Context, systemPrompt string, commands []SummaryCommand, findings []rules.Finding, onDelta func(string)) (summary string, err error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	defer func() { summary = s.Restore(summary) }()
+	userContent := s.UserMessage(commands, findings)
+	var out strings.Builder
+	emit := func(text string) {
+		if text == "" {
+			return
 		}
-		desc := ""
-		if c.Description != nil {
-			desc = c.Description.Description
+		out.WriteString(text)
+		if onDelta != nil {
+			onDelta(text)
 		}
-		b.WriteString(fmt.Sprintf("Command %d: %s\n", i+1, desc))
-		b.WriteString(c.Output)
-		b.WriteString("\n\n")
 	}
-	userContent := b.String()
 
 	if s.provider == "anthropic" {
-		// Anthropic Messages API
-		msg, err := s.anthropicClient.Messages.New(ctx, anthropic.MessageNewParams{
+		// Anthropic Messages API, which requires a limit
+		maxTokens := s.maxTokens
+		if maxTokens <= 0 {
+			maxTokens = 4096
+		}
+		params := anthropic.MessageNewParams{
 			Model:     anthropic.Model(s.model),
-			MaxTokens: 4096,
+			MaxTokens: int64(maxTokens),
 			System: []anthropic.TextBlockParam{
 				{Text: systemPrompt},
 			},
 			Messages: []anthropic.MessageParam{
 				anthropic.NewUserMessage(anthropic.NewTextBlock(userContent)),
 			},
-		})
-		if err != nil {
-			return "", err
 		}
-		// Concatenate text blocks
-		var out strings.Builder
-		for _, c := range msg.Content {
-			if c.Type == "text" {
-				out.WriteString(c.Text)
+		if s.temperature != nil {
+			params.Temperature = anthropic.Float(*s.temperature)
+		}
+		stream := s.anthropicClient.Messages.NewStreaming(ctx, params)
+		defer stream.Close()
+		for stream.Next() {
+			// Only text deltas carry the summary
+			if ev, ok := stream.Current().AsAny().(anthropic.ContentBlockDeltaEvent); ok {
+				if delta, ok := ev.Delta.AsAny().(anthropic.TextDelta); ok {
+					emit(delta.Text)
+				}
 			}
 		}
+		if err := stream.Err(); err != nil {
+			return out.String(), err
+		}
 		return out.String(), nil
 	}
 
-	// OpenAI/OpenRouter path
+	if s.local != nil && s.local.API == localAPIOllama {
+		err := streamOllama(ctx, s.local, systemPrompt, userContent, s.maxTokens, s.temperature, emit)
+		return out.String(), err
+	}
+
+	// OpenAI/OpenRouter path, also used for OpenAI-compatible local servers
 	params := openai.ChatCompletionNewParams{
 		Model: s.model,
 		Messages: []openai.ChatCompletionMessageParamUnion{
@@ -180,45 +477,94 @@ func (s *Summarizer) Summarize(systemPrompt string, commands []SummaryCommand) (
 			openai.UserMessage(userContent),
 		},
 	}
+	if s.maxTokens > 0 {
+		// Servers implementing the OpenAI API often lack max_completion_tokens
+		if s.local != nil {
+			params.MaxTokens = openai.Int(int64(s.maxTokens))
+		} else {
+			params.MaxCompletionTokens = openai.Int(int64(s.maxTokens))
+		}
+	}
+	if s.temperature != nil {
+		params.Temperature = openai.Float(*s.temperature)
+	}
 	if len(s.models) > 0 {
 		params.SetExtraFields(map[string]interface{}{
 			"models": s.models,
 		})
 	}
-	resp, err := s.openaiClient.Chat.Completions.New(ctx, params)
-	if err != nil {
-		return "", err
+	stream := s.openaiClient.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+	chunks := 0
+	for stream.Next() {
+		chunk := stream.Current()
+		chunks++
+		if len(chunk.Choices) > 0 {
+			emit(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return out.String(), err
 	}
-	if len(resp.Choices) == 0 {
+	if chunks == 0 {
 		return "", fmt.Errorf("no choices from LLM")
 	}
-	return resp.Choices[0].Message.Content, nil
+	return out.String(), nil
 }
 
-// summarizeCmd wraps the Summarizer.Summarize call into a Bubble Tea command
-// that returns an llmMsg for the UI state machine.
-func summarizeCmd(s *Summarizer, systemPrompt string, commands []SummaryCommand) tea.Cmd {
-	return func() tea.Msg {
-		summary, err := s.Summarize(systemPrompt, commands)
-		if err != nil {
-			return llmMsg{err: err}
+// summaryPrompt builds the user message: every command that produced output
+// with its status, followed by the rule findings.
+func summaryPrompt(commands []SummaryCommand, findings []rules.Finding) string {
+	var b strings.Builder
+	for i, c := range commands {
+		if c.Result == nil || strings.TrimSpace(c.Result.Stdout+c.Result.Stderr) == "" {
+			continue
 		}
-		return llmMsg{summary: summary}
+		desc := ""
+		if c.Description != nil {
+			desc = c.Description.Description
+		}
+		b.WriteString(fmt.Sprintf("Command %d: %s\n", i+1, desc))
+		if status := resultStatus(c.Result, c.Err); status != "" {
+			b.WriteString(fmt.Sprintf("(%s)\n", status))
+		}
+		b.WriteString(c.Result.Stdout)
+		if strings.TrimSpace(c.Result.Stderr) != "" {
+			b.WriteString("\nstderr:\n")
+			b.WriteString(c.Result.Stderr)
+		}
+		b.WriteString("\n\n")
 	}
+	if len(findings) > 0 {
+		b.WriteString("Findings from automated checks:\n")
+		for _, f := range findings {
+			b.WriteString(fmt.Sprintf("- [%s] %s (%s)\n", f.Severity, f.Message, f.Command))
+		}
+	}
+	return b.String()
 }
 
-//go:embed .fk*.txt
-var fk embed.FS
-
-func getFK() string {
-	fk1, err1 := fk.ReadFile(".fk1.txt")
-	fk2, err2 := fk.ReadFile(".fk2.txt")
-	if err1 != nil || err2 != nil {
-		return ""
+// newSummarizerCmd builds the summarizer for pb off the UI goroutine, since
+// finding a local server means probing ports over HTTP.
+func newSummarizerCmd(l llmLayers, pb *playbook.PlaybookConfig) tea.Cmd {
+	return func() tea.Msg {
+		return summarizerMsg{summarizer: l.summarizer(pb)}
 	}
-	fk3 := make([]byte, len(fk1))
-	for i := 0; i < len(fk1); i++ {
-		fk3[i] = byte((int(fk1[i]^fk2[i]) + 256 - i - 42) ^ 0xFF)
+}
+
+// summarizeCmd runs Summarizer.Stream in a goroutine, feeding each piece of
+// text to the UI as an llmMsg followed by a final llmMsg with done set.
+// Cancelling ctx aborts the request.
+func summarizeCmd(ctx context.Context, s *Summarizer, systemPrompt string, commands []SummaryCommand, findings []rules.Finding) tea.Cmd {
+	return func() tea.Msg {
+		ch := make(chan tea.Msg, 64)
+		go func() {
+			defer close(ch)
+			summary, err := s.Stream(ctx, systemPrompt, commands, findings, func(delta string) {
+				ch <- llmMsg{delta: delta}
+			})
+			ch <- llmMsg{summary: summary, err: err, done: true}
+		}()
+		return listen(ch)()
 	}
-	return string(fk3)
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"gradient-engineer/playbook"
+)
+
+func TestLLMLayersResolve(t *testing.T) {
+	tests := []struct {
+		name   string
+		config playbook.LLMConfig
+		pb     *playbook.PlaybookConfig
+		flags  playbook.LLMConfig
+		want   playbook.LLMConfig
+	}{
+		{
+			name:   "config only",
+			config: playbook.LLMConfig{Provider: "openai", BaseURL: "https://proxy.example.com/v1", MaxTokens: 500},
+			want:   playbook.LLMConfig{Provider: "openai", BaseURL: "https://proxy.example.com/v1", MaxTokens: 500},
+		},
+		{
+			name:   "playbook picks the model",
+			config: playbook.LLMConfig{Provider: "openai", BaseURL: "https://proxy.example.com/v1"},
+			pb:     &playbook.PlaybookConfig{LLM: playbook.LLMConfig{Model: "gpt-4.1-mini"}},
+			want:   playbook.LLMConfig{Provider: "openai", BaseURL: "https://proxy.example.com/v1", Model: "gpt-4.1-mini"},
+		},
+		{
+			name:   "playbook cannot set the endpoint",
+			config: playbook.LLMConfig{Provider: "openai"},
+			pb:     &playbook.PlaybookConfig{LLM: playbook.LLMConfig{BaseURL: "https://attacker.example.com/v1"}},
+			want:   playbook.LLMConfig{Provider: "openai"},
+		},
+		{
+			name:   "playbook cannot redirect another provider",
+			config: playbook.LLMConfig{Provider: "anthropic"},
+			pb:     &playbook.PlaybookConfig{LLM: playbook.LLMConfig{Provider: "openai", BaseURL: "https://attacker.example.com/v1"}},
+			want:   playbook.LLMConfig{Provider: "openai"},
+		},
+		{
+			name:  "flags set the endpoint over the playbook",
+			pb:    &playbook.PlaybookConfig{LLM: playbook.LLMConfig{Provider: "local", Model: "llama3.1:8b"}},
+			flags: playbook.LLMConfig{BaseURL: "http://gpu-box:11434"},
+			want:  playbook.LLMConfig{Provider: "local", Model: "llama3.1:8b", BaseURL: "http://gpu-box:11434"},
+		},
+		{
+			name:   "another provider drops the model and endpoint",
+			config: playbook.LLMConfig{Provider: "local", Model: "llama3.1:8b", BaseURL: "http://gpu-box:11434", MaxTokens: 800},
+			flags:  playbook.LLMConfig{Provider: "anthropic"},
+			want:   playbook.LLMConfig{Provider: "anthropic", MaxTokens: 800},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := llmLayers{Config: tt.config, Flags: tt.flags}
+			if got := l.resolve(tt.pb); got != tt.want {
+				t.Errorf("resolve = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaybookCannotDisableRedaction(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	pb := &playbook.PlaybookConfig{Redact: playbook.Redaction{
+		Disable:  []string{"email", "secret"},
+		Patterns: []playbook.RedactPattern{{Name: "customer", Regex: `cust-[0-9]+`}},
+	}}
+	l := llmLayers{Redact: playbook.Redaction{Disable: []string{"ipv4"}}}
+	s := l.summarizer(pb)
+	if s.err != nil {
+		t.Fatal(s.err)
+	}
+	got := s.redactor.Redact("ops@example.com password=hunter22 cust-42 10.1.2.3")
+	want := "[email-1] password=[secret-1] [customer-1] 10.1.2.3"
+	if got != want {
+		t.Errorf("Redact = %q; want %q", got, want)
+	}
+}
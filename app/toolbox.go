@@ -1,50 +1,93 @@
 package main
 
 import (
-	"archive/tar"
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"gradient-engineer/manifest"
+	"gradient-engineer/parser"
 	"gradient-engineer/playbook"
+	"gradient-engineer/rules"
 
-	"github.com/ulikunitz/xz"
 	"gopkg.in/yaml.v3"
 )
 
 // DiagnosticCommand represents a diagnostic command with its actual command and display name
 type DiagnosticCommand struct {
-	Command string                    // The actual command to execute
-	Display string                    // Human-readable display name
-	Spec    *playbook.PlaybookCommand // Pointer to the originating playbook command spec
-	Timeout time.Duration             // Timeout for the command execution
+	Command   string                    // The actual command line, for display
+	Argv      []string                  // Program and arguments to execute; empty if the binary was not found
+	Env       []string                  // Extra NAME=value environment entries
+	Display   string                    // Human-readable display name
+	Spec      *playbook.PlaybookCommand // Pointer to the originating playbook command spec
+	Timeout   time.Duration             // Timeout for the command execution; hitting it is a failure
+	StopAfter time.Duration             // Interrupt the command after this long and treat it as success; 0 lets it run to completion
+	Retention OutputRetention           // Part of the output kept for the UI and the summarizer
+	Parser    string                    // Name of the parser applied to stdout; empty for none
+	Program   string                    // Name of the program the command runs, for matching rules
+	Checks    []*rules.Check            // Expectations evaluated once the command finishes
+	Source    string                    // Where the binary was found ("toolbox" or "host"); empty if not found
+	Resolve   ResolvePolicy             // Policy the binary was resolved with
 }
 
 // Toolbox represents a downloaded and extracted toolbox
 type Toolbox struct {
-	URL      string                   // URL to download from
+	URLs     []string                 // Candidate URLs, one per repository, tried in order
+	URL      string                   // URL the archive was actually served from
+	ID       string                   // Playbook ID the archive must be built for
 	TempDir  string                   // Temporary directory where toolbox is extracted
 	Playbook *playbook.PlaybookConfig // Loaded playbook configuration
+	Rules    *rules.Engine            // Built-in and playbook rules, compiled with the playbook
+	Cache    *ToolboxCache            // Cache used for remote archives
+
+	// PlaybookFile, when set, is a local playbook used instead of the one
+	// shipped in the archive. Commands missing from the toolbox (or all of
+	// them, when no toolbox is available) are resolved against the host PATH.
+	PlaybookFile string
+
+	Resolve ResolvePolicy // Run-wide resolution policy; commands may override it, empty picks a platform default
+
+	PublicKey  ed25519.PublicKey // Key the archive manifest must be signed with; nil refuses to extract unless SkipVerify is set
+	SkipVerify bool              // Extract without checking the archive against its manifest
+
+	Progress func(DownloadProgress) // Optional callback for archive download progress
+
+	mu      sync.Mutex
+	running map[int]bool // Process groups of the commands still running
+	stopped bool         // Set by Stop; no command starts afterwards
 }
 
-// NewToolbox creates a new Toolbox instance
-func NewToolbox(toolboxRepo, playbookName string) *Toolbox {
-	// Construct the toolbox URL using the specified format
-	url := fmt.Sprintf("%s%s.%s.%s.tar.xz", toolboxRepo, playbookName, runtime.GOOS, runtime.GOARCH)
+// NewToolbox creates a new Toolbox instance. toolboxRepos are base URLs
+// (see repoBaseURL) that are tried in order until one serves the archive.
+func NewToolbox(toolboxRepos []string, playbookName string, cache *ToolboxCache) *Toolbox {
+	// Construct the toolbox URLs using the specified format
+	var urls []string
+	for _, repo := range toolboxRepos {
+		urls = append(urls, fmt.Sprintf("%s%s.%s.%s.tar.xz", repo, playbookName, runtime.GOOS, runtime.GOARCH))
+	}
 	return &Toolbox{
-		URL: url,
+		URLs:  urls,
+		ID:    playbookName,
+		Cache: cache,
 	}
 }
 
-// Download downloads and extracts the toolbox to a temporary directory
+// Download fetches the toolbox archive from the first repository that can
+// serve a verifiable copy, going through the cache for remote URLs, and
+// extracts it to a temporary directory
 func (t *Toolbox) Download() error {
 	// Create a temporary directory
 	tempDir, err := os.MkdirTemp("", "toolbox_*")
@@ -55,92 +98,126 @@ func (t *Toolbox) Download() error {
 	// Store the temp directory in the struct
 	t.TempDir = tempDir
 
-	// Download the file
-	var rc io.ReadCloser
-	if strings.HasPrefix(t.URL, "file://") {
-		localPath := strings.TrimPrefix(t.URL, "file://")
-		file, err := os.Open(localPath)
-		if err != nil {
-			return fmt.Errorf("failed to open local file: %w", err)
-		}
-		rc = file
-	} else {
-		resp, err := http.Get(t.URL)
+	var errs []error
+	for _, url := range t.URLs {
+		t.URL = url
+		archivePath, m, err := t.fetchVerified()
 		if err != nil {
-			return fmt.Errorf("failed to download file: %w", err)
-		}
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			return fmt.Errorf("bad status: %s", resp.Status)
+			errs = append(errs, err)
+			continue
 		}
-		rc = resp.Body
+		return t.extract(archivePath, m)
 	}
-	defer rc.Close()
+	t.URL = ""
+	if t.PlaybookFile != "" {
+		// A local playbook does not need a toolbox; run against the host
+		return nil
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no toolbox repository configured")
+	}
+	return errors.Join(errs...)
+}
 
-	// Create XZ reader
-	xzReader, err := xz.NewReader(rc)
+// fetchVerified fetches the archive from t.URL and, unless verification is
+// disabled, checks it against its manifest before anything is extracted.
+func (t *Toolbox) fetchVerified() (string, *manifest.Manifest, error) {
+	// Without a key the archive could only be checked against a manifest
+	// from the same server, so it is not fetched at all
+	if !t.SkipVerify && t.PublicKey == nil {
+		return "", nil, &VerificationError{URL: t.URL, Err: errNoPublicKey}
+	}
+	archivePath, err := t.fetch(t.URL, t.Progress)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", t.URL, err)
+	}
+	if t.SkipVerify {
+		return archivePath, nil, nil
+	}
+	m, err := t.verifyArchive(archivePath)
 	if err != nil {
-		return fmt.Errorf("failed to create XZ reader: %w", err)
+		return "", nil, err
 	}
+	return archivePath, m, nil
+}
 
-	// Create tar reader
-	tarReader := tar.NewReader(xzReader)
+// extract unpacks a fetched archive into the temporary directory.
+func (t *Toolbox) extract(archivePath string, m *manifest.Manifest) error {
+	rc, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer rc.Close()
 
-	// Extract files
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break // End of tar archive
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read tar header: %w", err)
+	// Absolute symlinks into /nix are fine: proot binds toolbox/nix there
+	mounts := map[string]string{"/nix": "toolbox/nix"}
+	var files map[string]string
+	if m != nil {
+		files = m.Files
+	}
+	if err := extractTarXz(rc, t.TempDir, mounts, files); err != nil {
+		if errors.Is(err, errFileMismatch) {
+			return &VerificationError{URL: t.URL, Err: err}
 		}
+		return err
+	}
+	return nil
+}
 
-		// Construct the full path
-		targetPath := filepath.Join(tempDir, header.Name)
-
-		// Ensure the target directory exists
-		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-			return fmt.Errorf("failed to create directory: %w", err)
+// fetch returns a local path for url. Local file:// URLs are used in place,
+// remote ones go through the cache.
+func (t *Toolbox) fetch(url string, progress func(DownloadProgress)) (string, error) {
+	if strings.HasPrefix(url, "file://") {
+		localPath := strings.TrimPrefix(url, "file://")
+		if _, err := os.Stat(localPath); err != nil {
+			return "", fmt.Errorf("failed to open local file: %w", err)
 		}
+		return localPath, nil
+	}
+	if t.Cache == nil {
+		return "", fmt.Errorf("no toolbox cache configured for %s", url)
+	}
+	return t.Cache.Fetch(url, progress)
+}
 
-		switch header.Typeflag {
-		case tar.TypeDir:
-			// Create directory with write permissions for the owner
-			// We use 0755 to ensure we can write to the directory, regardless of original permissions
-			if err := os.MkdirAll(targetPath, 0755); err != nil {
-				return fmt.Errorf("failed to create directory %s: %w", targetPath, err)
-			}
-
-		case tar.TypeReg:
-			// Create regular file
-			file, err := os.OpenFile(targetPath, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
-			if err != nil {
-				return fmt.Errorf("failed to create file %s: %w", targetPath, err)
-			}
-
-			if _, err := io.Copy(file, tarReader); err != nil {
-				file.Close()
-				return fmt.Errorf("failed to copy file content: %w", err)
-			}
-			file.Close()
-
-		case tar.TypeSymlink:
-			// Create symbolic link
-			if err := os.Symlink(header.Linkname, targetPath); err != nil {
-				return fmt.Errorf("failed to create symlink %s -> %s: %w", targetPath, header.Linkname, err)
-			}
+// Stop kills every command that is still running and keeps new ones from
+// starting. Their output goes to files and their stop_after timers die with
+// this process, so collectors such as vmstat 1 would otherwise run forever.
+func (t *Toolbox) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	for pgid := range t.running {
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+	}
+}
 
-		default:
-			return fmt.Errorf("unsupported file type: %c (%d) for %s", header.Typeflag, header.Typeflag, header.Name)
-		}
+// track records the process group of a started command. It returns false,
+// having killed the group, if Stop was called in the meantime.
+func (t *Toolbox) track(pgid int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+		return false
 	}
+	if t.running == nil {
+		t.running = map[int]bool{}
+	}
+	t.running[pgid] = true
+	return true
+}
 
-	return nil
+func (t *Toolbox) untrack(pgid int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.running, pgid)
 }
 
-// Cleanup removes the temporary directory and all its contents
+// Cleanup stops any command still running and removes the temporary
+// directory and all its contents
 func (t *Toolbox) Cleanup() error {
+	t.Stop()
 	if t.TempDir == "" {
 		return nil // Nothing to clean up
 	}
@@ -162,101 +239,272 @@ func (t *Toolbox) GetDiagnosticCommands() ([]DiagnosticCommand, error) {
 		return []DiagnosticCommand{}, nil
 	}
 
-	// Load playbook from the extracted toolbox archive only
+	// Load playbook from the local file if given, otherwise from the
+	// extracted toolbox archive
 	playbookPath := filepath.Join(t.TempDir, "toolbox", "playbook.yaml")
-	data, err := os.ReadFile(playbookPath)
-	if err != nil {
-		return []DiagnosticCommand{}, fmt.Errorf("failed to read playbook.yaml from toolbox: %w", err)
+	if t.PlaybookFile != "" {
+		playbookPath = t.PlaybookFile
 	}
-	var cfg playbook.PlaybookConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return []DiagnosticCommand{}, fmt.Errorf("failed to parse playbook.yaml: %w", err)
+	cfg, err := loadPlaybook(playbookPath)
+	if err != nil {
+		return []DiagnosticCommand{}, err
 	}
 	// Store playbook on toolbox for later use (e.g., system prompt)
-	t.Playbook = &cfg
+	t.Playbook = cfg
+	if t.Rules, err = rules.New(cfg.Rules); err != nil {
+		return nil, fmt.Errorf("failed to load rules: %w", err)
+	}
 
 	toolboxPath := path.Join(t.TempDir, "toolbox")
-	storeDir := filepath.Join(toolboxPath, "nix", "store")
+	idx, err := loadBinIndex(toolboxPath)
+	if err != nil {
+		return nil, err
+	}
 	prootPath := filepath.Join(toolboxPath, "proot")
-	prootPrefix := fmt.Sprintf("%s -b %s/nix:/nix", prootPath, toolboxPath)
+	prootArgv := []string{prootPath, "-b", toolboxPath + "/nix:/nix"}
 
 	var result []DiagnosticCommand
 	for i := range cfg.Commands {
 		c := cfg.Commands[i]
-		parts := strings.Fields(c.Command)
-		if len(parts) == 0 {
+		if strings.TrimSpace(c.Command) == "" {
 			return nil, fmt.Errorf("command '%s' is empty", c.Command)
 		}
-		binName := parts[0]
-		args := parts[1:]
-
-		// Try to locate the binary inside toolbox nix store under */bin or */sbin
-		resolved := ""
-		if entries, err := os.ReadDir(storeDir); err == nil {
-			for _, e := range entries {
-				if !e.IsDir() {
-					continue
-				}
-				candidate := filepath.Join(storeDir, e.Name(), "bin", binName)
-				if st, err := os.Stat(candidate); err == nil && !st.IsDir() && (st.Mode()&0o111 != 0) {
-					resolved = candidate
-					break
-				}
-				candidate = filepath.Join(storeDir, e.Name(), "sbin", binName)
-				if st, err := os.Stat(candidate); err == nil && !st.IsDir() && (st.Mode()&0o111 != 0) {
-					resolved = candidate
-					break
-				}
+
+		policy, err := ParseResolvePolicy(c.Resolve)
+		if err != nil {
+			return nil, fmt.Errorf("command '%s': %w", c.Command, err)
+		}
+		if policy == "" {
+			policy = t.defaultResolvePolicy()
+		}
+
+		// Shell commands run through sh -c, everything else is split into
+		// argv here and executed directly
+		var env, argv []string
+		if c.Shell {
+			argv = []string{"sh", "-c", c.Command}
+		} else {
+			env, argv, err = splitCommand(c.Command)
+			if err != nil {
+				return nil, err
 			}
 		}
+		binName := argv[0]
+		program := filepath.Base(binName)
+		if c.Shell {
+			program = shellProgram(c.Command)
+		}
+		// For shell commands the package picks what the shell runs, not
+		// the shell itself
+		pkg := c.Package
+		if c.Shell {
+			pkg = ""
+		}
 
-		var cmdStr string
-		if resolved != "" {
-			if len(args) > 0 {
-				cmdStr = prootPrefix + " " + resolved + " " + strings.Join(args, " ")
-			} else {
-				cmdStr = prootPrefix + " " + resolved
+		// Unresolved commands keep an empty Argv and fail individually
+		// when executed
+		var resolvedArgv []string
+		var source string
+		if policy != ResolveHostOnly {
+			if resolved := findInStore(toolboxPath, idx, binName, pkg); resolved != "" {
+				resolvedArgv = append(append(append([]string{}, prootArgv...), resolved), argv[1:]...)
+				source = sourceToolbox
 			}
-		} else {
-			if runtime.GOOS == "darwin" {
-				cmdStr = c.Command // Nix + PRoot not available on macOS
-			} else {
-				return nil, fmt.Errorf("binary for command '%s' not found in toolbox nix store", binName)
+		}
+		if source == "" && policy != ResolveToolboxOnly {
+			if resolved := findOnHost(binName); resolved != "" {
+				resolvedArgv = append([]string{resolved}, argv[1:]...)
+				source = sourceHost
+			}
+		}
+
+		// A toolbox shell finds the commands it runs in the toolbox, and on
+		// the host too if the policy allows it
+		if c.Shell && source == sourceToolbox {
+			dirs := storeBinDirs(toolboxPath, idx, c.Package)
+			if policy == ResolveToolboxThenHost {
+				dirs = append(dirs, os.Getenv("PATH"))
 			}
+			env = append(env, "PATH="+strings.Join(dirs, string(os.PathListSeparator)))
 		}
 
-		timeout := 5 * time.Second
+		var display []string
+		for _, a := range append(env, argv...) {
+			display = append(display, quoteArg(a))
+		}
+		if c.Shell {
+			display = []string{c.Command}
+		}
+
+		// Streaming collectors get the default timeout on top of the time
+		// they are allowed to collect for
+		stopAfter := time.Duration(c.StopAfterSeconds) * time.Second
+		timeout := stopAfter + 5*time.Second
 		if c.TimeoutSeconds > 0 {
 			timeout = time.Duration(c.TimeoutSeconds) * time.Second
 		}
+		if stopAfter > 0 && timeout <= stopAfter {
+			return nil, fmt.Errorf("command '%s': timeout_seconds must be longer than stop_after_seconds", c.Command)
+		}
+		retention, err := newOutputRetention(c.MaxLines, c.MaxBytes, c.Keep)
+		if err != nil {
+			return nil, fmt.Errorf("command '%s': %w", c.Command, err)
+		}
+		if _, ok := parser.Lookup(c.Parser); c.Parser != "" && !ok {
+			return nil, fmt.Errorf("command '%s': unknown parser %q (want one of %s)", c.Command, c.Parser, strings.Join(parser.Names(), ", "))
+		}
+		checks, err := rules.CompileChecks(c.Parser, c.Checks)
+		if err != nil {
+			return nil, fmt.Errorf("command '%s': %w", c.Command, err)
+		}
 		result = append(result, DiagnosticCommand{
-			Command: cmdStr,
-			Display: c.Description,
-			Spec:    &cfg.Commands[i],
-			Timeout: timeout,
+			Command:   strings.Join(display, " "),
+			Argv:      resolvedArgv,
+			Env:       env,
+			Display:   c.Description,
+			Spec:      &cfg.Commands[i],
+			Timeout:   timeout,
+			StopAfter: stopAfter,
+			Retention: retention,
+			Parser:    c.Parser,
+			Program:   program,
+			Checks:    checks,
+			Source:    source,
+			Resolve:   policy,
 		})
 	}
 	return result, nil
 }
 
+// loadPlaybook reads and parses the playbook at path.
+func loadPlaybook(path string) (*playbook.PlaybookConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read playbook: %w", err)
+	}
+	var cfg playbook.PlaybookConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// CommandResult is the captured outcome of a diagnostic command. Stdout and
+// Stderr hold the part of the output selected by the command's retention
+// settings; the full output stays on disk in StdoutFile and StderrFile until
+// the toolbox is cleaned up.
+type CommandResult struct {
+	Stdout     string
+	Stderr     string
+	StdoutFile string
+	StderrFile string
+	Truncated  bool   // Stdout or Stderr is shorter than the full output
+	ExitCode   int    // -1 if the process did not exit normally
+	Signal     string // Signal that terminated the process, if any
+	Started    time.Time
+	Ended      time.Time
+	TimedOut   bool  // The command was killed because it hit its timeout
+	Stopped    bool  // The command was interrupted after its stop_after period, as intended
+	Parsed     any   // Structured stdout, if the command has a parser and it succeeded
+	ParseErr   error // Why the parser rejected stdout
+}
+
+// FullStdout returns the complete stdout of the command.
+func (r *CommandResult) FullStdout() string {
+	return readFull(r.StdoutFile, r.Stdout)
+}
+
+// FullStderr returns the complete stderr of the command.
+func (r *CommandResult) FullStderr() string {
+	return readFull(r.StderrFile, r.Stderr)
+}
+
+// readFull reads a captured output file, falling back to the retained part
+// if the file is gone.
+func readFull(path, retained string) string {
+	if path == "" {
+		return retained
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return retained
+	}
+	return string(data)
+}
+
+// Duration is the wall time the command ran for.
+func (r *CommandResult) Duration() time.Duration {
+	return r.Ended.Sub(r.Started)
+}
+
 // ExecuteDiagnosticCommand executes a single diagnostic command and returns its output
 func (t *Toolbox) ExecuteDiagnosticCommand(cmd DiagnosticCommand) (string, error) {
+	res, err := t.RunDiagnosticCommand(cmd, nil)
+	return displayOutput(cmd, res, err)
+}
+
+// displayOutput condenses the result of cmd into its retained combined
+// output, or an error carrying the output if the command failed.
+func displayOutput(cmd DiagnosticCommand, res *CommandResult, err error) (string, error) {
+	if res == nil {
+		return "", err
+	}
+	output := res.Stdout + res.Stderr
+	if err != nil {
+		return "", fmt.Errorf("command '%s' failed: %w\nOutput: %s", cmd.Display, err, output)
+	}
+	return output, nil
+}
+
+// resultStatus describes how a command ended when that is anything other
+// than a clean exit: exit code, terminating signal or timeout.
+func resultStatus(res *CommandResult, err error) string {
+	var parts []string
+	switch {
+	case res == nil:
+	case res.TimedOut:
+		parts = append(parts, fmt.Sprintf("timed out after %.0fs", res.Duration().Seconds()))
+	case res.Stopped:
+		parts = append(parts, fmt.Sprintf("stopped after %.0fs", res.Duration().Seconds()))
+	case res.Signal != "":
+		parts = append(parts, "killed by "+res.Signal)
+	case res.ExitCode != 0:
+		parts = append(parts, fmt.Sprintf("exit code %d", res.ExitCode))
+	}
+	if res == nil && err != nil {
+		parts = append(parts, err.Error())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// RunDiagnosticCommand executes a single diagnostic command, capturing stdout
+// and stderr separately. The result is nil if the command could not be
+// started; otherwise it is returned even when the command failed or timed
+// out, with whatever output was produced.
+//
+// Commands with a StopAfter period are sent SIGINT once it elapses, which is
+// how collectors like vmstat 1 are meant to end, and count as successful.
+// Commands still running at Timeout are killed and reported as timed out.
+//
+// onLine, if non-nil, is called with every line of stdout as it is produced.
+func (t *Toolbox) RunDiagnosticCommand(cmd DiagnosticCommand, onLine func(string)) (*CommandResult, error) {
 	if t.TempDir == "" {
-		return "", fmt.Errorf("toolbox not downloaded yet")
+		return nil, fmt.Errorf("toolbox not downloaded yet")
 	}
 
 	// If command resolution failed earlier, return a descriptive error now.
-	if strings.TrimSpace(cmd.Command) == "" {
+	if len(cmd.Argv) == 0 {
+		where := "toolbox nix store"
+		switch cmd.Resolve {
+		case ResolveToolboxThenHost:
+			where = "toolbox nix store or host PATH"
+		case ResolveHostOnly:
+			where = "host PATH"
+		}
 		if cmd.Spec != nil && cmd.Spec.Command != "" {
-			return "", fmt.Errorf("binary for command '%s' not found in toolbox nix store", cmd.Spec.Command)
+			return nil, fmt.Errorf("binary for command '%s' not found in %s", cmd.Spec.Command, where)
 		}
-		return "", fmt.Errorf("command binary not found in toolbox nix store")
-	}
-
-	// Split the command into parts for exec.Command
-	parts := strings.Fields(cmd.Command)
-	if len(parts) == 0 {
-		return "", fmt.Errorf("empty command")
+		return nil, fmt.Errorf("command binary not found in %s", where)
 	}
 
 	// Create context with timeout
@@ -267,22 +515,118 @@ func (t *Toolbox) ExecuteDiagnosticCommand(cmd DiagnosticCommand) (string, error
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	// Create the command with context
-	execCmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
-	execCmd.WaitDelay = timeout
+	// Create the command with context. It runs in its own process group so
+	// that signals also reach whatever runs under proot or sh.
+	execCmd := exec.CommandContext(ctx, cmd.Argv[0], cmd.Argv[1:]...)
+	execCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	execCmd.Cancel = func() error {
+		return syscall.Kill(-execCmd.Process.Pid, syscall.SIGKILL)
+	}
+	execCmd.WaitDelay = time.Second
 	execCmd.Dir = t.TempDir
+	if len(cmd.Env) > 0 {
+		execCmd.Env = append(os.Environ(), cmd.Env...)
+	}
+	// The full output goes to disk; only the retained part is kept in memory
+	outDir := filepath.Join(t.TempDir, "output")
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	stdout, err := os.CreateTemp(outDir, "stdout-*.log")
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture output: %w", err)
+	}
+	defer stdout.Close()
+	stderr, err := os.CreateTemp(outDir, "stderr-*.log")
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture output: %w", err)
+	}
+	defer stderr.Close()
+	execCmd.Stdout = stdout
+	execCmd.Stderr = stderr
+	var lines *lineWriter
+	if onLine != nil {
+		lines = &lineWriter{emit: onLine}
+		execCmd.Stdout = io.MultiWriter(stdout, lines)
+	}
 
 	// Execute and capture output
-	output, err := execCmd.CombinedOutput()
-	if err != nil && ctx.Err() != context.DeadlineExceeded {
-		return "", fmt.Errorf("command '%s' failed: %w\nOutput: %s", cmd.Display, err, string(output))
+	res := &CommandResult{Started: time.Now()}
+	if err := execCmd.Start(); err != nil {
+		return nil, fmt.Errorf("command '%s' failed to start: %w", cmd.Display, err)
+	}
+	pgid := execCmd.Process.Pid
+	if !t.track(pgid) {
+		_ = execCmd.Wait()
+		return nil, fmt.Errorf("command '%s' was stopped", cmd.Display)
+	}
+	var stopped atomic.Bool
+	if cmd.StopAfter > 0 {
+		timer := time.AfterFunc(cmd.StopAfter, func() {
+			stopped.Store(true)
+			_ = syscall.Kill(-execCmd.Process.Pid, syscall.SIGINT)
+		})
+		defer timer.Stop()
+	}
+	err = execCmd.Wait()
+	t.untrack(pgid)
+	if lines != nil {
+		lines.Flush()
+	}
+	res.Ended = time.Now()
+	res.StdoutFile = stdout.Name()
+	res.StderrFile = stderr.Name()
+	var cutOut, cutErr bool
+	res.Stdout, cutOut = cmd.Retention.Trim(res.FullStdout())
+	res.Stderr, cutErr = cmd.Retention.Trim(res.FullStderr())
+	res.Truncated = cutOut || cutErr
+	if cmd.Parser != "" {
+		res.Parsed, res.ParseErr = parser.Parse(cmd.Parser, res.FullStdout())
+	}
+	res.ExitCode = execCmd.ProcessState.ExitCode()
+	if ws, ok := execCmd.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		res.Signal = ws.Signal().String()
+	}
+	res.TimedOut = ctx.Err() == context.DeadlineExceeded
+	res.Stopped = stopped.Load() && !res.TimedOut
+
+	switch {
+	case res.TimedOut:
+		return res, fmt.Errorf("timed out after %s", timeout)
+	case res.Stopped:
+		return res, nil
+	case err != nil:
+		return res, err
 	}
+	return res, nil
+}
+
+// lineWriter calls emit with every complete line written to it. A trailing
+// partial line is held back until Flush.
+type lineWriter struct {
+	emit func(string)
+	buf  []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
 
-	lines := strings.Split(string(output), "\n")
-	if len(lines) > 100 {
-		lines = lines[len(lines)-100:]
+// Flush emits any buffered partial line.
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.emit(string(w.buf))
+		w.buf = nil
 	}
-	return strings.Join(lines, "\n"), nil
 }
 
 // RunSpecificDiagnosticCommand runs a specific diagnostic command by its display name
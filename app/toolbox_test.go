@@ -0,0 +1,53 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestStopKillsRunningCommands(t *testing.T) {
+	tb := &Toolbox{TempDir: t.TempDir()}
+	// The background sleep shares the process group but not the pipe to sh
+	cmd := DiagnosticCommand{
+		Display: "collector",
+		Argv:    []string{"sh", "-c", "sleep 60 & sleep 60"},
+		Timeout: time.Minute,
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := tb.RunDiagnosticCommand(cmd, nil)
+		done <- err
+	}()
+
+	var pgid int
+	for deadline := time.Now().Add(5 * time.Second); pgid == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("command did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+		tb.mu.Lock()
+		for p := range tb.running {
+			pgid = p
+		}
+		tb.mu.Unlock()
+	}
+
+	tb.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("command still running after Stop")
+	}
+	// The group is gone once its last member has been reaped by init
+	for deadline := time.Now().Add(5 * time.Second); syscall.Kill(-pgid, 0) == nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("process group survived Stop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := tb.RunDiagnosticCommand(cmd, nil); err == nil {
+		t.Error("a command started after Stop")
+	}
+}
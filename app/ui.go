@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"strings"
 	"time"
 
+	"gradient-engineer/rules"
+
 	"github.com/charmbracelet/bubbles/v2/spinner"
 	"github.com/charmbracelet/bubbles/v2/viewport"
 	tea "github.com/charmbracelet/bubbletea/v2"
@@ -19,6 +22,7 @@ var (
 	runningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("69"))
 	successStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
 	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
 	footerStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("250")).Italic(true)
 	descStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
 )
@@ -33,6 +37,7 @@ const (
 	statusRunning
 	statusSuccess
 	statusError
+	statusTimedOut
 )
 
 // resultMsg is a Bubble Tea message carrying the result of a command
@@ -40,7 +45,7 @@ const (
 // we can update the correct entry.
 type resultMsg struct {
 	index  int
-	output string
+	result *CommandResult // nil if the command could not be started
 	err    error
 }
 
@@ -48,9 +53,45 @@ type downloadMsg struct {
 	err error
 }
 
+// summarizerMsg carries the summarizer, which may have to probe for local
+// LLM servers before it is ready.
+type summarizerMsg struct {
+	summarizer *Summarizer
+}
+
+// outputLineMsg carries a line of stdout from a command that is still
+// running.
+type outputLineMsg struct {
+	index int
+	line  string
+}
+
+// downloadProgressMsg carries toolbox download progress.
+type downloadProgressMsg DownloadProgress
+
+// chanMsg wraps a message produced by a background goroutine together with
+// the channel it arrived on, so that Update can keep listening for more.
+type chanMsg struct {
+	msg tea.Msg
+	ch  <-chan tea.Msg
+}
+
+// llmMsg carries the AI summary: a piece of text while it is streamed, then
+// the complete text or the error that ended it with done set.
 type llmMsg struct {
+	delta   string
 	summary string
 	err     error
+	done    bool
+}
+
+// sample is one execution of a command, kept in the history when the
+// command is re-run.
+type sample struct {
+	status commandStatus
+	result *CommandResult
+	err    error
+	failed []rules.Finding
 }
 
 type model struct {
@@ -58,28 +99,56 @@ type model struct {
 	commands []DiagnosticCommand
 
 	statuses []commandStatus
-	outputs  []string
+	results  []*CommandResult
 	errors   []error
+	live     [][]string // stdout lines of running commands, trimmed to the last 100
+
+	history  [][]sample // Earlier samples of each command, oldest first
+	viewing  []int      // Samples back from the latest shown for each command; 0 is the latest
+	showDiff bool       // Show how the viewed sample differs from the one before it
+	diffs    []string   // That difference for each command, rendered by updateDiff
 
 	vp viewport.Model
 
 	spin spinner.Model
 
 	startTime time.Time
+	runStart  time.Time // Start of the current run of all commands
 
-	downloaded bool
+	downloaded  bool
+	downloadErr error
+	progress    DownloadProgress
+	stale       []string // Cached copies served because the repository failed
 
-	showDetails bool
+	showDetails bool   // Tab: show the details of every command
+	cursor      int    // Selected command
+	expanded    []bool // Commands whose details were opened with Enter
+	pager       *pager // Full-screen output of the selected command, if open
+
+	// Terminal size, for the pager
+	width, height int
+
+	// Line of the selected command in the content, and whether the viewport
+	// should scroll to it on the next View
+	cursorLine   int
+	followCursor bool
 
 	// LLM
-	summarizing   bool
-	summary       string // rendered ANSI summary
-	summaryErr    error
-	summaryNotice string
+	summarizing    bool
+	summary        string // rendered ANSI summary
+	summaryText    string // raw Markdown summary
+	summaryErr     error
+	summaryNotice  string
+	summaryPending bool // Summarize once every command has finished
+
+	failed   [][]rules.Finding // Failed checks of each finished command
+	findings []rules.Finding   // Rule findings and failed checks, evaluated once every command has finished
 
 	done bool
 
-	summarizer *Summarizer
+	llm        llmLayers          // Settings the playbook's llm: block is merged with
+	summarizer *Summarizer        // Created once the toolbox is downloaded
+	stopLLM    context.CancelFunc // Aborts the summary being streamed
 
 	// Time it took to execute all commands (seconds), captured when summarization starts
 	execSeconds float64
@@ -89,8 +158,8 @@ type model struct {
 }
 
 // NewModel constructs a model initialised with all diagnostic commands in a
-// pending state.
-func NewModel(tb *Toolbox) *model {
+// pending state. llm selects the summarizer together with the playbook.
+func NewModel(tb *Toolbox, llm llmLayers) *model {
 	cmds, _ := tb.GetDiagnosticCommands()
 	n := len(cmds)
 
@@ -101,8 +170,14 @@ func NewModel(tb *Toolbox) *model {
 		toolbox:  tb,
 		commands: cmds,
 		statuses: make([]commandStatus, n),
-		outputs:  make([]string, n),
+		results:  make([]*CommandResult, n),
 		errors:   make([]error, n),
+		live:     make([][]string, n),
+		failed:   make([][]rules.Finding, n),
+		expanded: make([]bool, n),
+		history:  make([][]sample, n),
+		viewing:  make([]int, n),
+		diffs:    make([]string, n),
 		vp:       vp,
 		spin: func() spinner.Model {
 			s := spinner.New()
@@ -110,8 +185,10 @@ func NewModel(tb *Toolbox) *model {
 			s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
 			return s
 		}(),
-		startTime:  time.Now(),
-		summarizer: NewSummarizer(),
+		startTime:      time.Now(),
+		runStart:       time.Now(),
+		summaryPending: true,
+		llm:            llm,
 	}
 }
 
@@ -130,20 +207,48 @@ func (m *model) Init() tea.Cmd {
 
 // summarizeCmd moved to summarize.go
 
-// downloadToolboxCmd runs the toolbox download in a goroutine.
+// downloadToolboxCmd runs the toolbox download in a goroutine, streaming
+// progress updates followed by the final downloadMsg.
 func downloadToolboxCmd(tb *Toolbox) tea.Cmd {
 	return func() tea.Msg {
-		err := tb.Download()
-		return downloadMsg{err: err}
+		ch := make(chan tea.Msg, 16)
+		go func() {
+			defer close(ch)
+			tb.Progress = func(p DownloadProgress) {
+				ch <- downloadProgressMsg(p)
+			}
+			err := tb.Download()
+			ch <- downloadMsg{err: err}
+		}()
+		return listen(ch)()
 	}
 }
 
-// runCommandCmd wraps the synchronous Toolbox.ExecuteDiagnosticCommand method
-// in an asynchronous Bubble Tea command.
+// listen waits for the next message on ch. It returns nil once ch is closed.
+func listen(ch <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-ch
+		if !ok {
+			return nil
+		}
+		return chanMsg{msg: msg, ch: ch}
+	}
+}
+
+// runCommandCmd wraps the synchronous Toolbox.RunDiagnosticCommand method
+// in an asynchronous Bubble Tea command, streaming stdout lines as they are
+// produced followed by the final resultMsg.
 func runCommandCmd(tb *Toolbox, cmd DiagnosticCommand, idx int) tea.Cmd {
 	return func() tea.Msg {
-		out, err := tb.ExecuteDiagnosticCommand(cmd)
-		return resultMsg{index: idx, output: out, err: err}
+		ch := make(chan tea.Msg, 64)
+		go func() {
+			defer close(ch)
+			res, err := tb.RunDiagnosticCommand(cmd, func(line string) {
+				ch <- outputLineMsg{index: idx, line: line}
+			})
+			ch <- resultMsg{index: idx, result: res, err: err}
+		}()
+		return listen(ch)()
 	}
 }
 
@@ -151,44 +256,89 @@ func runCommandCmd(tb *Toolbox, cmd DiagnosticCommand, idx int) tea.Cmd {
 // any follow-up commands.
 func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case chanMsg:
+		model, cmd := m.Update(msg.msg)
+		return model, tea.Batch(cmd, listen(msg.ch))
+
+	case downloadProgressMsg:
+		if notice := DownloadProgress(msg).staleNotice(); notice != "" {
+			m.stale = append(m.stale, notice)
+			return m, nil
+		}
+		m.progress = DownloadProgress(msg)
+		return m, nil
+
 	case downloadMsg:
 		if msg.err != nil {
-			// download failed, stop program
-			fmt.Println(msg.err)
+			// Keep the error on screen until the user quits; nothing from
+			// the toolbox gets executed.
+			m.downloadErr = msg.err
 			m.done = true
-			return m, tea.Quit
+			return m, nil
 		}
 		m.downloaded = true
 		// Populate commands now that toolbox is available
 		commands, err := m.toolbox.GetDiagnosticCommands()
 		if err != nil {
-			fmt.Println(err)
+			// Shown like a failed download: the playbook cannot be run
+			m.downloadErr = err
 			m.done = true
-			return m, tea.Quit
+			return m, nil
 		}
 		m.commands = commands
 		n := len(m.commands)
 		m.statuses = make([]commandStatus, n)
-		m.outputs = make([]string, n)
+		m.results = make([]*CommandResult, n)
 		m.errors = make([]error, n)
+		m.live = make([][]string, n)
+		m.failed = make([][]rules.Finding, n)
+		m.expanded = make([]bool, n)
+		m.history = make([][]sample, n)
+		m.viewing = make([]int, n)
+		m.diffs = make([]string, n)
+		m.cursor = 0
 
-		// start executing diagnostic commands
-		var cmds []tea.Cmd
+		// The playbook may choose the model, so the summarizer is only
+		// built now, alongside the commands
+		cmds := []tea.Cmd{newSummarizerCmd(m.llm, m.toolbox.Playbook)}
 		for i, cmd := range m.commands {
 			m.statuses[i] = statusRunning
 			cmds = append(cmds, runCommandCmd(m.toolbox, cmd, i))
 		}
 		return m, tea.Batch(cmds...)
 
+	case summarizerMsg:
+		// Its data notice is shown from here on
+		m.summarizer = msg.summarizer
+		if m.summaryPending && m.idle() {
+			// The commands finished first and are waiting for it
+			return m, m.startSummary()
+		}
+		return m, nil
+
+	case outputLineMsg:
+		live := append(m.live[msg.index], msg.line)
+		if len(live) > 100 {
+			live = live[len(live)-100:]
+		}
+		m.live[msg.index] = live
+		return m, nil
+
 	case resultMsg:
 		// Command finished.
-		if msg.err != nil {
+		m.results[msg.index] = msg.result
+		m.live[msg.index] = nil
+		m.failed[msg.index] = runChecks(m.commands[msg.index], msg.result)
+		if msg.result != nil && msg.result.TimedOut {
+			m.statuses[msg.index] = statusTimedOut
+			m.errors[msg.index] = msg.err
+		} else if msg.err != nil {
 			m.statuses[msg.index] = statusError
 			m.errors[msg.index] = msg.err
 		} else {
 			m.statuses[msg.index] = statusSuccess
-			m.outputs[msg.index] = msg.output
 		}
+		m.updateDiff(msg.index)
 
 		// Check whether all commands are finished.
 		allDone := true
@@ -199,38 +349,29 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 		if allDone {
-			if !m.summarizing && m.summary == "" {
-				m.execSeconds = time.Since(m.startTime).Seconds()
-				m.requestScrollToBottom = true
-				// If summarizer is disabled (no API key), skip summarization and show a notice.
-				if m.summarizer == nil || m.summarizer.disabled {
-					m.summaryNotice = "No API key provided; skipping AI summary.\nSet the API key with OPENAI_API_KEY, OPENROUTER_API_KEY, or ANTHROPIC_API_KEY."
-					return m, nil
-				}
-				m.summarizing = true
-				var sc []SummaryCommand
-				for i := range m.commands {
-					sc = append(sc, SummaryCommand{
-						Description: m.commands[i].Spec,
-						Output:      m.outputs[i],
-					})
-				}
-				if m.toolbox == nil || m.toolbox.Playbook == nil || m.toolbox.Playbook.SystemPrompt == "" {
-					m.summaryErr = fmt.Errorf("system_prompt is required in playbook")
-					return m, nil
-				}
-				systemPrompt := m.toolbox.Playbook.SystemPrompt
-				return m, summarizeCmd(m.summarizer, systemPrompt, sc)
-			}
+			return m, m.finishRun()
 		}
 		// No follow-up commands here.
 		return m, nil
 
 	case llmMsg:
+		if !msg.done {
+			// Follow the text as it grows unless the user scrolled away
+			m.requestScrollToBottom = m.requestScrollToBottom || m.vp.AtBottom()
+			m.summaryText += msg.delta
+			// Placeholders are restored once complete; the final message
+			// carries the restored text
+			if rendered, err := glamour.Render(m.summarizer.Restore(m.summaryText), "dark"); err == nil {
+				m.summary = rendered
+			}
+			return m, nil
+		}
 		m.summarizing = false
+		m.cancelSummary()
 		if msg.err != nil {
 			m.summaryErr = msg.err
 		} else {
+			m.summaryText = msg.summary
 			rendered, err := glamour.Render(msg.summary, "dark")
 			if err != nil {
 				m.summaryErr = err
@@ -246,6 +387,10 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 
 	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		if m.pager != nil {
+			m.pager.setSize(msg.Width, msg.Height)
+		}
 		// Keep the viewport dimensions in sync with the terminal.
 		m.vp.SetWidth(msg.Width)
 		// Leave a single line at the bottom for the prompt/scroll bar.
@@ -257,12 +402,66 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m.quit()
+		}
+		if m.pager != nil {
+			closed, cmd := m.pager.Update(msg)
+			if closed {
+				m.pager = nil
+			}
+			return m, cmd
+		}
 		switch msg.String() {
-		case "q", "ctrl+c", "esc":
-			return m, tea.Quit
+		case "q", "esc":
+			return m.quit()
 		case "tab":
 			m.showDetails = !m.showDetails
 			return m, nil
+		case "down", "j":
+			m.moveCursor(1)
+			return m, nil
+		case "up", "k":
+			m.moveCursor(-1)
+			return m, nil
+		case "enter":
+			if m.cursor < len(m.expanded) {
+				m.expanded[m.cursor] = !m.expanded[m.cursor]
+			}
+			return m, nil
+		case "r":
+			if m.downloaded && m.cursor < len(m.commands) && !m.summarizing &&
+				m.statuses[m.cursor] != statusRunning && m.statuses[m.cursor] != statusPending {
+				return m, m.rerun(m.cursor)
+			}
+			return m, nil
+		case "R":
+			if m.idle() {
+				return m, m.rerunAll()
+			}
+			return m, nil
+		case "[":
+			if m.cursor < len(m.commands) && m.viewing[m.cursor] < len(m.history[m.cursor]) {
+				m.viewing[m.cursor]++
+				m.expanded[m.cursor] = true
+				m.updateDiff(m.cursor)
+			}
+			return m, nil
+		case "]":
+			if m.cursor < len(m.commands) && m.viewing[m.cursor] > 0 {
+				m.viewing[m.cursor]--
+				m.expanded[m.cursor] = true
+				m.updateDiff(m.cursor)
+			}
+			return m, nil
+		case "c":
+			m.showDiff = !m.showDiff
+			return m, nil
+		case "o":
+			if m.cursor < len(m.commands) {
+				m.pager = newPager(m.pagerTitle(m.cursor), m.pagerContent(m.cursor), m.width, m.height)
+			}
+			return m, nil
 		}
 		// Delegate other key events to the viewport for scrolling.
 		var cmd tea.Cmd
@@ -270,7 +469,12 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 
 	case tea.MouseMsg:
-		// Delegate mouse events (including wheel) to the viewport for scrolling.
+		// Delegate mouse events (including wheel) to the pager or the
+		// viewport for scrolling.
+		if m.pager != nil {
+			_, cmd := m.pager.Update(msg)
+			return m, cmd
+		}
 		var cmd tea.Cmd
 		m.vp, cmd = m.vp.Update(msg)
 		return m, cmd
@@ -279,15 +483,276 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// finishRun is called whenever no command is left running. It refreshes the
+// findings and, after the first run or a full re-run, starts summarizing.
+func (m *model) finishRun() tea.Cmd {
+	if m.toolbox != nil {
+		m.findings = evaluateFindings(m.toolbox.Rules, m.commands, m.results, m.failed)
+	}
+	if !m.summaryPending {
+		return nil
+	}
+	m.execSeconds = time.Since(m.runStart).Seconds()
+	m.requestScrollToBottom = true
+	if m.summarizer == nil {
+		// Started once the summarizer is ready
+		return nil
+	}
+	return m.startSummary()
+}
+
+// startSummary sends the results of the run to the summarizer.
+func (m *model) startSummary() tea.Cmd {
+	m.summaryPending = false
+	// If summarizer is disabled (no API key), skip summarization and show a notice.
+	if m.summarizer.disabled {
+		m.summaryNotice = m.summarizer.DisabledNotice()
+		return nil
+	}
+	if m.toolbox == nil || m.toolbox.Playbook == nil || m.toolbox.Playbook.SystemPrompt == "" {
+		m.summaryErr = fmt.Errorf("system_prompt is required in playbook")
+		return nil
+	}
+	var sc []SummaryCommand
+	for i := range m.commands {
+		sc = append(sc, SummaryCommand{
+			Description: m.commands[i].Spec,
+			Result:      m.results[i],
+			Err:         m.errors[i],
+		})
+	}
+	m.summarizing = true
+	ctx, cancel := context.WithCancel(context.Background())
+	m.stopLLM = cancel
+	return summarizeCmd(ctx, m.summarizer, m.toolbox.Playbook.SystemPrompt, sc, m.findings)
+}
+
+// cancelSummary aborts the summary request, if one is in flight.
+func (m *model) cancelSummary() {
+	if m.stopLLM != nil {
+		m.stopLLM()
+		m.stopLLM = nil
+	}
+}
+
+// quit stops the summary request and the running commands and exits the
+// program.
+func (m *model) quit() (tea.Model, tea.Cmd) {
+	m.cancelSummary()
+	if m.toolbox != nil {
+		m.toolbox.Stop()
+	}
+	return m, tea.Quit
+}
+
+// idle reports whether no command is running and no summary is being
+// generated, so that commands may be re-run.
+func (m *model) idle() bool {
+	if !m.downloaded || m.summarizing {
+		return false
+	}
+	for _, st := range m.statuses {
+		if st == statusRunning || st == statusPending {
+			return false
+		}
+	}
+	return true
+}
+
+// rerun runs command i again, keeping its previous outcome in the history.
+func (m *model) rerun(i int) tea.Cmd {
+	m.history[i] = append(m.history[i], m.latest(i))
+	m.viewing[i] = 0
+	m.statuses[i] = statusRunning
+	m.results[i] = nil
+	m.errors[i] = nil
+	m.failed[i] = nil
+	m.live[i] = nil
+	m.updateDiff(i)
+	return runCommandCmd(m.toolbox, m.commands[i], i)
+}
+
+// rerunAll runs every command again and regenerates the summary.
+func (m *model) rerunAll() tea.Cmd {
+	m.runStart = time.Now()
+	m.execSeconds = 0
+	m.summaryPending = true
+	m.summary, m.summaryText, m.summaryErr, m.summaryNotice = "", "", nil, ""
+	m.findings = nil
+	var cmds []tea.Cmd
+	for i := range m.commands {
+		cmds = append(cmds, m.rerun(i))
+	}
+	return tea.Batch(cmds...)
+}
+
+// latest is the most recent outcome of command i.
+func (m *model) latest(i int) sample {
+	return sample{
+		status: m.statuses[i],
+		result: m.results[i],
+		err:    m.errors[i],
+		failed: m.failed[i],
+	}
+}
+
+// viewed is the outcome of command i selected with [ and ]: the latest one,
+// or an earlier sample from the history.
+func (m *model) viewed(i int) sample {
+	if m.viewing[i] == 0 {
+		return m.latest(i)
+	}
+	h := m.history[i]
+	return h[len(h)-m.viewing[i]]
+}
+
+// previous is the sample taken just before the viewed one, if any.
+func (m *model) previous(i int) (sample, bool) {
+	h := m.history[i]
+	idx := len(h) - m.viewing[i] - 1
+	if idx < 0 {
+		return sample{}, false
+	}
+	return h[idx], true
+}
+
+// updateDiff renders how the sample viewed for command i differs from the
+// one before it. It runs when either changes rather than on every frame.
+func (m *model) updateDiff(i int) {
+	m.diffs[i] = ""
+	if prev, ok := m.previous(i); ok {
+		m.diffs[i] = renderDiff(sampleStdout(prev), sampleStdout(m.viewed(i)))
+	}
+}
+
+// moveCursor selects the command delta positions away from the current one
+// and scrolls it into view.
+func (m *model) moveCursor(delta int) {
+	if len(m.commands) == 0 {
+		return
+	}
+	m.cursor = min(max(m.cursor+delta, 0), len(m.commands)-1)
+	m.followCursor = true
+}
+
+// pagerTitle describes command i in the pager header.
+func (m *model) pagerTitle(i int) string {
+	cmd := m.commands[i]
+	title := cmd.Command
+	if cmd.Spec != nil {
+		title = cmd.Spec.Command
+	}
+	if cmd.Display != "" {
+		title += " — " + cmd.Display
+	}
+	return title
+}
+
+// pagerContent is the plain-text output of command i: everything it printed
+// so far while it runs, or its full output and status once it finished.
+func (m *model) pagerContent(i int) string {
+	s := m.viewed(i)
+	switch s.status {
+	case statusPending:
+		return "(not started)"
+	case statusRunning:
+		return strings.Join(m.live[i], "\n")
+	}
+	var b strings.Builder
+	if s.result != nil {
+		b.WriteString(s.result.FullStdout())
+		if errOut := s.result.FullStderr(); errOut != "" {
+			b.WriteString("\nstderr:\n")
+			b.WriteString(errOut)
+		}
+	}
+	if status := resultStatus(s.result, s.err); status != "" {
+		fmt.Fprintf(&b, "\n(%s)\n", status)
+	} else if s.err != nil {
+		fmt.Fprintf(&b, "\nERROR: %v\n", s.err)
+	}
+	for _, f := range s.failed {
+		fmt.Fprintf(&b, "%s: %s\n", strings.ToUpper(f.Severity), f.Message)
+	}
+	return b.String()
+}
+
+// exitCode is the process exit code once the TUI quits, with the same
+// precedence as a headless run: a toolbox or playbook that could not be
+// loaded, then failed commands, then failed checks.
+func (m *model) exitCode() int {
+	if m.downloadErr != nil {
+		return exitRunFailed
+	}
+	for _, err := range m.errors {
+		if err != nil {
+			return exitCommandFailed
+		}
+	}
+	return checksExitCode(m.failed)
+}
+
+// runReport captures the state of the run for --report.
+func (m *model) runReport() *RunReport {
+	report := &RunReport{
+		SchemaVersion: reportSchemaVersion,
+		Host:          collectHostFacts(),
+		StartedAt:     m.startTime,
+		Duration:      m.execSeconds,
+		Commands:      []ReportCommand{},
+		Findings:      m.findings,
+		Summary:       ReportSummary{Text: m.summaryText},
+	}
+	if report.Findings == nil {
+		report.Findings = []rules.Finding{}
+	}
+	if report.Duration == 0 {
+		report.Duration = time.Since(m.startTime).Seconds()
+	}
+	if m.downloadErr != nil {
+		report.Error = m.downloadErr.Error()
+	}
+	if m.toolbox != nil {
+		report.ToolboxURL = m.toolbox.URL
+		if m.toolbox.Playbook != nil {
+			report.Playbook = ReportPlaybook{ID: m.toolbox.Playbook.ID, Name: m.toolbox.Playbook.Name}
+		}
+	}
+	for i, cmd := range m.commands {
+		// Commands still running when the user quit have no outcome yet
+		if m.statuses[i] != statusPending && m.statuses[i] != statusRunning {
+			report.Commands = append(report.Commands, newReportCommand(cmd, m.results[i], m.errors[i], m.failed[i]))
+		}
+	}
+	if m.summaryErr != nil {
+		report.Summary.Error = m.summaryErr.Error()
+	}
+	if m.summaryNotice != "" {
+		report.Summary.Skipped = "no API key provided"
+	}
+	return report
+}
+
 // View produces a string representation of the current program state for the
 // terminal user interface.
 func (m *model) View() string {
+	if m.pager != nil {
+		return m.pager.View()
+	}
 	// Build the content string and assign it to the viewport.
 	m.vp.SetContent(m.generateContent())
 	if m.requestScrollToBottom {
 		m.vp.GotoBottom()
 		m.requestScrollToBottom = false
 	}
+	if m.followCursor {
+		if m.cursorLine < m.vp.YOffset {
+			m.vp.SetYOffset(m.cursorLine)
+		} else if m.cursorLine >= m.vp.YOffset+m.vp.Height() {
+			m.vp.SetYOffset(m.cursorLine - m.vp.Height() + 1)
+		}
+		m.followCursor = false
+	}
 	return m.vp.View()
 }
 
@@ -297,17 +762,43 @@ func (m *model) generateContent() string {
 		iconPending = "●"
 		iconSuccess = "✓"
 		iconError   = "✗"
+		iconTimeout = "⧗"
+		iconWarning = "!"
+		iconCrit    = "‼"
 	)
 
 	// Build the commands section
 	var cmdBuf strings.Builder
 
-	if !m.downloaded {
+	if m.downloadErr != nil {
+		cmdBuf.WriteString(errorStyle.Render(fmt.Sprintf("%s %v", iconError, m.downloadErr)))
+		cmdBuf.WriteString("\n")
+	} else if m.downloaded {
+		if m.toolbox != nil && m.toolbox.URL != "" {
+			cmdBuf.WriteString(descStyle.Render("Toolbox served by " + m.toolbox.URL))
+			cmdBuf.WriteString("\n\n")
+			for _, notice := range m.stale {
+				cmdBuf.WriteString(warningStyle.Render("⚠ Toolbox: " + notice))
+				cmdBuf.WriteString("\n\n")
+			}
+		} else if m.toolbox != nil && m.toolbox.PlaybookFile != "" {
+			cmdBuf.WriteString(descStyle.Render("No cached toolbox; running " + m.toolbox.PlaybookFile + " against the host PATH"))
+			cmdBuf.WriteString("\n\n")
+		}
+		if m.summarizer != nil && m.summarizer.DataNotice() != "" {
+			cmdBuf.WriteString(warningStyle.Render("⚠ AI summary: " + m.summarizer.DataNotice()))
+			cmdBuf.WriteString("\n\n")
+		}
+	} else {
 		// Show downloading placeholder
 		cmdBuf.WriteString(runningStyle.Render(fmt.Sprintf("%s Downloading toolbox...", m.spin.View())))
+		if p := formatProgress(m.progress); p != "" {
+			cmdBuf.WriteString(" " + descStyle.Render(p+" from "+m.progress.URL))
+		}
 		cmdBuf.WriteString("\n")
 	}
 
+	selectedOffset := 0
 	for i, cmd := range m.commands {
 		icon := iconPending
 		switch m.statuses[i] {
@@ -317,6 +808,8 @@ func (m *model) generateContent() string {
 			icon = iconSuccess
 		case statusError:
 			icon = iconError
+		case statusTimedOut:
+			icon = iconTimeout
 		}
 
 		var lineStyle lipgloss.Style
@@ -327,34 +820,45 @@ func (m *model) generateContent() string {
 			lineStyle = successStyle
 		case statusError:
 			lineStyle = errorStyle
+		case statusTimedOut:
+			lineStyle = warningStyle
 		default:
 			lineStyle = pendingStyle
 		}
+		// A command that ran fine but failed a check shows the worst severity
+		if m.statuses[i] == statusSuccess {
+			switch rules.Worst(m.failed[i]) {
+			case rules.SeverityCritical:
+				icon, lineStyle = iconCrit, errorStyle
+			case rules.SeverityWarning:
+				icon, lineStyle = iconWarning, warningStyle
+			}
+		}
 		// Render command and lighter description
 		cmdText := cmd.Command
 		if cmd.Spec != nil && strings.TrimSpace(cmd.Spec.Command) != "" {
 			cmdText = cmd.Spec.Command
 		}
-		line := lineStyle.Render(fmt.Sprintf("%s %s", icon, cmdText))
+		marker := "  "
+		if i == m.cursor {
+			marker = titleStyle.Render("›") + " "
+			selectedOffset = strings.Count(cmdBuf.String(), "\n")
+		}
+		line := marker + lineStyle.Render(fmt.Sprintf("%s %s", icon, cmdText))
+		if cmd.Source != "" {
+			line += " " + descStyle.Render("["+cmd.Source+"]")
+		}
 		if strings.TrimSpace(cmd.Display) != "" {
 			line += " " + descStyle.Render("— "+cmd.Display)
 		}
+		if n := len(m.history[i]); n > 0 {
+			line += " " + descStyle.Render(fmt.Sprintf("(%d samples)", n+1))
+		}
 		cmdBuf.WriteString(line)
 		cmdBuf.WriteString("\n")
 
-		if m.showDetails {
-			switch m.statuses[i] {
-			case statusSuccess:
-				if m.outputs[i] != "" {
-					cmdBuf.WriteString(indent(m.outputs[i], "    "))
-					cmdBuf.WriteString("\n")
-				}
-			case statusError:
-				if m.errors[i] != nil {
-					cmdBuf.WriteString(indent(fmt.Sprintf("ERROR: %v", m.errors[i]), "    "))
-					cmdBuf.WriteString("\n")
-				}
-			}
+		if m.showDetails || m.expanded[i] {
+			cmdBuf.WriteString(m.commandDetails(i))
 		}
 	}
 
@@ -380,6 +884,7 @@ func (m *model) generateContent() string {
 	var commandsBox string
 	if header != "" {
 		commandsBox = header + "\n\n" + cmdBuf.String()
+		selectedOffset += 2
 	} else {
 		commandsBox = cmdBuf.String()
 	}
@@ -389,27 +894,50 @@ func (m *model) generateContent() string {
 	b.WriteString(generateBanner(time.Since(m.startTime).Seconds()))
 
 	b.WriteString("\n")
-	b.WriteString(footerStyle.Render("Tab: toggle details; q: quit; up/down or mouse: scroll"))
+	b.WriteString(footerStyle.Render("j/k: select; Enter: details; o: open output; r/R: re-run one/all; Tab: all details; q: quit; PgUp/PgDn or mouse: scroll"))
 
 	b.WriteString("\n\n")
+	m.cursorLine = strings.Count(b.String(), "\n") + selectedOffset
 	b.WriteString(commandsBox)
 
 	// If we have finished executing commands, show the elapsed time above the summary section
 	if m.execSeconds > 0 {
 		b.WriteString("\n\n")
 		b.WriteString(successStyle.Render(fmt.Sprintf("Executing commands finished in %.1f seconds.", m.execSeconds)))
-	}
 
-	if m.summarizing {
 		b.WriteString("\n\n")
-		b.WriteString(runningStyle.Render(fmt.Sprintf("%s Summarizing results with AI…", m.spin.View())))
+		b.WriteString(renderGradientHeader(" Findings ", time.Since(m.startTime).Seconds()))
+		b.WriteString("\n")
+		if len(m.findings) == 0 {
+			b.WriteString(successStyle.Render("No issues found by the automated checks."))
+		}
+		for i, f := range m.findings {
+			style := descStyle
+			switch f.Severity {
+			case rules.SeverityCritical:
+				style = errorStyle
+			case rules.SeverityWarning:
+				style = warningStyle
+			}
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(style.Render(severityIcon(f.Severity)+" "+f.Message) + " " + descStyle.Render("— "+f.Command))
+		}
 	}
+
 	if m.summary != "" {
 		b.WriteString("\n\n")
 		b.WriteString(renderGradientHeader(" AI Summary ", time.Since(m.startTime).Seconds()))
+		b.WriteString(" " + descStyle.Render(m.summarizer.Label()))
 		b.WriteString("\n")
 		b.WriteString(m.summary)
 	}
+	// The spinner stays below the summary while it streams in
+	if m.summarizing {
+		b.WriteString("\n\n")
+		b.WriteString(runningStyle.Render(fmt.Sprintf("%s Summarizing results with AI (%s)…", m.spin.View(), m.summarizer.Label())))
+	}
 	if m.summaryNotice != "" {
 		b.WriteString("\n\n")
 		b.WriteString(errorStyle.Render(m.summaryNotice))
@@ -422,6 +950,127 @@ func (m *model) generateContent() string {
 	return b.String()
 }
 
+// commandDetails renders the captured stdout, stderr and exit status of
+// command i for the detail view, or its output so far while it runs. When
+// the command was re-run, it shows the sample picked with [ and ], or how
+// that sample differs from the one before it.
+func (m *model) commandDetails(i int) string {
+	s := m.viewed(i)
+	if s.status == statusRunning && len(m.live[i]) > 0 {
+		return indent(strings.Join(m.live[i], "\n"), "    ") + "\n"
+	}
+	if s.status == statusPending || s.status == statusRunning {
+		return ""
+	}
+	var b strings.Builder
+	if n := len(m.history[i]); n > 0 {
+		label := fmt.Sprintf("sample %d of %d", n+1-m.viewing[i], n+1)
+		if s.result != nil {
+			label += ", taken " + s.result.Started.Format("15:04:05")
+		}
+		label += "; [/]: older/newer; c: "
+		if m.showDiff {
+			label += "full output"
+		} else {
+			label += "changes"
+		}
+		b.WriteString(descStyle.Render(indent("("+label+")", "    ")))
+		b.WriteString("\n")
+	}
+	if _, ok := m.previous(i); ok && m.showDiff {
+		b.WriteString(m.diffs[i])
+	} else if res := s.result; res != nil {
+		if out := strings.TrimRight(res.Stdout, "\n"); out != "" {
+			b.WriteString(indent(out, "    "))
+			b.WriteString("\n")
+		}
+		if errOut := strings.TrimRight(res.Stderr, "\n"); errOut != "" {
+			b.WriteString(descStyle.Render(indent("stderr:", "    ")))
+			b.WriteString("\n")
+			b.WriteString(errorStyle.Render(indent(errOut, "    ")))
+			b.WriteString("\n")
+		}
+	}
+	status := resultStatus(s.result, s.err)
+	switch {
+	case s.status == statusError && status == "":
+		b.WriteString(errorStyle.Render(indent(fmt.Sprintf("ERROR: %v", s.err), "    ")))
+		b.WriteString("\n")
+	case s.status == statusError:
+		b.WriteString(errorStyle.Render(indent("ERROR: "+status, "    ")))
+		b.WriteString("\n")
+	case s.status == statusTimedOut:
+		b.WriteString(warningStyle.Render(indent("TIMED OUT: "+status+"; output above is partial", "    ")))
+		b.WriteString("\n")
+	case status != "":
+		b.WriteString(descStyle.Render(indent("("+status+")", "    ")))
+		b.WriteString("\n")
+	}
+	for _, f := range s.failed {
+		style := warningStyle
+		if f.Severity == rules.SeverityCritical {
+			style = errorStyle
+		}
+		b.WriteString(style.Render(indent(strings.ToUpper(f.Severity)+": "+f.Message, "    ")))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// sampleStdout is the retained stdout of s, or "" if it has none.
+func sampleStdout(s sample) string {
+	if s.result == nil {
+		return ""
+	}
+	return s.result.Stdout
+}
+
+// renderDiff shows the lines removed from before in red and the lines added
+// in after in green, with unchanged lines dimmed.
+func renderDiff(before, after string) string {
+	var b strings.Builder
+	for _, d := range diffLines(splitLines(before), splitLines(after)) {
+		switch d.op {
+		case '-':
+			b.WriteString(errorStyle.Render("  - " + d.text))
+		case '+':
+			b.WriteString(successStyle.Render("  + " + d.text))
+		default:
+			b.WriteString(descStyle.Render("    " + d.text))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// formatProgress renders byte counts, throughput and ETA of a download.
+func formatProgress(p DownloadProgress) string {
+	if p.Attempt == 0 {
+		return ""
+	}
+	var parts []string
+	if p.Total > 0 {
+		parts = append(parts, fmt.Sprintf("%s / %s (%d%%)", formatBytes(p.Done), formatBytes(p.Total), p.Done*100/p.Total))
+	} else {
+		parts = append(parts, formatBytes(p.Done))
+	}
+	if elapsed := time.Since(p.Started).Seconds(); elapsed > 0.5 && p.Done > p.Resumed {
+		rate := float64(p.Done-p.Resumed) / elapsed
+		parts = append(parts, formatBytes(int64(rate))+"/s")
+		if p.Total > 0 {
+			eta := time.Duration(float64(p.Total-p.Done)/rate) * time.Second
+			parts = append(parts, "ETA "+eta.Round(time.Second).String())
+		}
+	}
+	if p.Resumed > 0 {
+		parts = append(parts, "resumed")
+	}
+	if p.Attempt > 1 {
+		parts = append(parts, fmt.Sprintf("attempt %d", p.Attempt))
+	}
+	return strings.Join(parts, ", ")
+}
+
 // indent prefixes every line in text with prefix.
 func indent(text, prefix string) string {
 	lines := strings.Split(text, "\n")
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+
+	"gradient-engineer/manifest"
+)
+
+// toolboxPublicKey is the base64-encoded ed25519 key toolbox manifests must be
+// signed with. Release builds pin it with
+// -ldflags "-X main.toolboxPublicKey=<key>".
+var toolboxPublicKey = ""
+
+// errNoPublicKey is returned instead of extracting a toolbox that cannot be
+// verified because no key is pinned or given.
+var errNoPublicKey = errors.New("no public key to check the manifest signature against; pass --toolbox-pubkey, or --skip-verify to run unverified toolboxes")
+
+// Mismatches between a correctly signed manifest and the toolbox that was
+// requested, e.g. a replayed archive for another playbook or platform.
+var (
+	errManifestID      = errors.New("manifest is for another playbook")
+	errManifestOS      = errors.New("manifest is for another operating system")
+	errManifestArch    = errors.New("manifest is for another architecture")
+	errManifestArchive = errors.New("manifest describes another archive")
+)
+
+// VerificationError reports an archive that failed integrity checks. The
+// toolbox is never extracted or executed when this is returned.
+type VerificationError struct {
+	URL string
+	Err error
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("toolbox verification failed for %s: %v", e.URL, e.Err)
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+// PinnedPublicKey returns the public key compiled into the binary, or nil
+// for development builds that do not pin one.
+func PinnedPublicKey() (ed25519.PublicKey, error) {
+	if toolboxPublicKey == "" {
+		return nil, nil
+	}
+	return manifest.ParsePublicKey(toolboxPublicKey)
+}
+
+// verifyArchive checks the archive at archivePath against the manifest
+// published next to it, which must carry a valid signature by t.PublicKey.
+// The manifest is returned so that extraction can check individual files.
+func (t *Toolbox) verifyArchive(archivePath string) (*manifest.Manifest, error) {
+	fail := func(format string, args ...any) error {
+		return &VerificationError{URL: t.URL, Err: fmt.Errorf(format, args...)}
+	}
+	if t.PublicKey == nil {
+		return nil, fail("%w", errNoPublicKey)
+	}
+
+	manifestURL := t.URL + manifest.Suffix
+	manifestPath, err := t.fetch(manifestURL, t.Progress)
+	if err != nil {
+		return nil, fail("failed to fetch manifest: %w", err)
+	}
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fail("failed to read manifest: %w", err)
+	}
+
+	sigPath, err := t.fetch(manifestURL+manifest.SignatureSuffix, t.Progress)
+	if err != nil {
+		return nil, fail("failed to fetch manifest signature: %w", err)
+	}
+	sig, err := os.ReadFile(sigPath)
+	if err != nil {
+		return nil, fail("failed to read manifest signature: %w", err)
+	}
+	if err := manifest.Verify(data, sig, t.PublicKey); err != nil {
+		return nil, fail("manifest signature: %w", err)
+	}
+
+	m, err := manifest.Parse(data)
+	if err != nil {
+		return nil, fail("%w", err)
+	}
+	// The signature only proves who built the manifest, not that it is the
+	// one that was asked for
+	switch archive := path.Base(t.URL); {
+	case m.ID != t.ID:
+		return nil, fail("%w: %q, want %q", errManifestID, m.ID, t.ID)
+	case m.OS != runtime.GOOS:
+		return nil, fail("%w: %q, want %q", errManifestOS, m.OS, runtime.GOOS)
+	case m.Arch != runtime.GOARCH:
+		return nil, fail("%w: %q, want %q", errManifestArch, m.Arch, runtime.GOARCH)
+	case m.Archive != archive:
+		return nil, fail("%w: %q, want %q", errManifestArchive, m.Archive, archive)
+	}
+	sum, err := manifest.HashFile(archivePath)
+	if err != nil {
+		return nil, fail("failed to hash archive: %w", err)
+	}
+	if sum != m.ArchiveSHA256 {
+		return nil, fail("archive SHA-256 %s does not match manifest %s", sum, m.ArchiveSHA256)
+	}
+	return m, nil
+}
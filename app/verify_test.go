@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"gradient-engineer/manifest"
+)
+
+func TestVerifyArchive(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "debug-linux." + runtime.GOOS + "." + runtime.GOARCH + ".tar.xz"
+
+	tests := []struct {
+		name    string
+		edit    func(m *manifest.Manifest)
+		pub     ed25519.PublicKey
+		wantErr bool
+		wantIs  error
+	}{
+		{name: "valid", pub: pub},
+		{name: "other playbook", pub: pub, edit: func(m *manifest.Manifest) { m.ID = "other" }, wantErr: true, wantIs: errManifestID},
+		{name: "other os", pub: pub, edit: func(m *manifest.Manifest) { m.OS = "plan9" }, wantErr: true, wantIs: errManifestOS},
+		{name: "other arch", pub: pub, edit: func(m *manifest.Manifest) { m.Arch = "mips" }, wantErr: true, wantIs: errManifestArch},
+		{name: "other archive", pub: pub, edit: func(m *manifest.Manifest) { m.Archive = "old.tar.xz" }, wantErr: true, wantIs: errManifestArchive},
+		{name: "other contents", pub: pub, edit: func(m *manifest.Manifest) { m.ArchiveSHA256 = "00" }, wantErr: true},
+		{name: "wrong key", pub: otherPub, wantErr: true},
+		{name: "no key", wantErr: true, wantIs: errNoPublicKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			archivePath := filepath.Join(dir, name)
+			writeFile(t, archivePath, []byte("archive"))
+			sum, err := manifest.HashFile(archivePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := manifest.Manifest{ID: "debug-linux", OS: runtime.GOOS, Arch: runtime.GOARCH, Archive: name, ArchiveSHA256: sum}
+			if tt.edit != nil {
+				tt.edit(&m)
+			}
+			data, err := json.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeFile(t, archivePath+manifest.Suffix, data)
+			writeFile(t, archivePath+manifest.Suffix+manifest.SignatureSuffix, manifest.Sign(data, key))
+
+			tb := &Toolbox{URL: "file://" + archivePath, ID: "debug-linux", PublicKey: tt.pub}
+			_, err = tb.verifyArchive(archivePath)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("verifyArchive: %v", err)
+				}
+				return
+			}
+			var verr *VerificationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("verifyArchive = %v; want a VerificationError", err)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("verifyArchive = %v; want %v", err, tt.wantIs)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,150 @@
+package binindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileName is the location of the index inside the toolbox directory.
+const FileName = "bin-index.json"
+
+// Index maps every binary shipped in a toolbox Nix store to the store paths
+// providing it, most preferred first.
+type Index struct {
+	Packages []string           `json:"packages"` // Playbook packages in preference order
+	Binaries map[string][]Entry `json:"binaries"`
+}
+
+// Entry is a single binary in the store.
+type Entry struct {
+	Path    string `json:"path"`              // Slash-separated path relative to the toolbox directory, e.g. nix/store/<hash>-procps-3.3.17/bin/top
+	Package string `json:"package,omitempty"` // Playbook package the store path was installed for; empty for dependencies
+}
+
+// Build scans the bin and sbin directories of every path in storeDir, which
+// must be <toolboxDir>/nix/store. packages maps store path names
+// (<hash>-<name>) to the playbook package they belong to; order lists the
+// playbook packages in preference order. Binaries of listed packages come
+// first in package order, followed by dependencies sorted by store path.
+func Build(storeDir string, packages map[string]string, order []string) (*Index, error) {
+	entries, err := os.ReadDir(storeDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+	idx := &Index{Packages: order, Binaries: map[string][]Entry{}}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		for _, dir := range []string{"bin", "sbin"} {
+			bins, err := os.ReadDir(filepath.Join(storeDir, e.Name(), dir))
+			if err != nil {
+				continue
+			}
+			for _, b := range bins {
+				if b.IsDir() {
+					continue
+				}
+				name := b.Name()
+				idx.Binaries[name] = append(idx.Binaries[name], Entry{
+					Path:    path.Join("nix", "store", e.Name(), dir, name),
+					Package: packages[e.Name()],
+				})
+			}
+		}
+	}
+
+	for _, list := range idx.Binaries {
+		sort.Slice(list, func(i, j int) bool { return idx.less(list[i], list[j]) })
+	}
+	return idx, nil
+}
+
+// Load reads the index at path.
+func Load(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("failed to parse binary index: %w", err)
+	}
+	return &idx, nil
+}
+
+// Write stores the index at path.
+func (idx *Index) Write(path string) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Lookup returns the preferred entry for binary name. When pkg is not empty
+// only entries installed for that package are considered.
+func (idx *Index) Lookup(name, pkg string) (Entry, bool) {
+	for _, e := range idx.Binaries[name] {
+		if pkg == "" || e.Package == pkg {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Dirs returns the distinct bin and sbin directories of the index in the
+// same preference order as binaries, for use as a PATH.
+func (idx *Index) Dirs() []string {
+	seen := map[string]bool{}
+	var dirs []Entry
+	for _, list := range idx.Binaries {
+		for _, e := range list {
+			d := path.Dir(e.Path)
+			if !seen[d] {
+				seen[d] = true
+				dirs = append(dirs, Entry{Path: d, Package: e.Package})
+			}
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool { return idx.less(dirs[i], dirs[j]) })
+	out := make([]string, len(dirs))
+	for i, d := range dirs {
+		out[i] = d.Path
+	}
+	return out
+}
+
+// less orders entries of listed packages first, in package order, and
+// everything else by path.
+func (idx *Index) less(a, b Entry) bool {
+	ra, rb := idx.rank(a.Package), idx.rank(b.Package)
+	if ra != rb {
+		return ra < rb
+	}
+	return a.Path < b.Path
+}
+
+func (idx *Index) rank(pkg string) int {
+	for i, p := range idx.Packages {
+		if pkg != "" && p == pkg {
+			return i
+		}
+	}
+	return len(idx.Packages)
+}
+
+// PackageOf guesses the package of a store path name (<hash>-<name>-<version>)
+// without an index, by matching pkg against the name.
+func PackageOf(storeName, pkg string) bool {
+	_, rest, ok := strings.Cut(storeName, "-")
+	if !ok {
+		return false
+	}
+	return rest == pkg || strings.HasPrefix(rest, pkg+"-")
+}
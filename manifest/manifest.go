@@ -0,0 +1,93 @@
+package manifest
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Manifest describes a toolbox archive: the digest of the archive itself and
+// of every regular file inside it, keyed by the tar entry name.
+type Manifest struct {
+	ID            string            `json:"id"`
+	OS            string            `json:"os"`
+	Arch          string            `json:"arch"`
+	Archive       string            `json:"archive"`
+	ArchiveSHA256 string            `json:"archive_sha256"`
+	Files         map[string]string `json:"files"`
+}
+
+// Suffix is appended to the archive URL to locate its manifest; the detached
+// signature lives at the manifest URL plus SignatureSuffix.
+const (
+	Suffix          = ".manifest.json"
+	SignatureSuffix = ".sig"
+)
+
+// Parse decodes a manifest document.
+func Parse(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if m.ArchiveSHA256 == "" {
+		return nil, fmt.Errorf("manifest has no archive_sha256")
+	}
+	return &m, nil
+}
+
+// Sign returns the base64-encoded ed25519 signature of data.
+func Sign(data []byte, key ed25519.PrivateKey) []byte {
+	sig := ed25519.Sign(key, data)
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+}
+
+// Verify checks a base64-encoded detached signature of data.
+func Verify(data, sig []byte, key ed25519.PublicKey) error {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+	if !ed25519.Verify(key, data, raw) {
+		return fmt.Errorf("signature does not match")
+	}
+	return nil
+}
+
+// ParsePublicKey decodes a base64-encoded ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid ed25519 public key")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// ParsePrivateKey decodes a base64-encoded ed25519 seed.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(raw) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid ed25519 private key seed")
+	}
+	return ed25519.NewKeyFromSeed(raw), nil
+}
+
+// HashFile returns the hex-encoded SHA-256 digest of the file at path.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FreeMemory is the Mem: line of free.
+type FreeMemory struct {
+	Total     float64 `json:"total"`
+	Used      float64 `json:"used"`
+	Free      float64 `json:"free"`
+	Shared    float64 `json:"shared"`
+	BuffCache float64 `json:"buff_cache"`
+	Available float64 `json:"available"`
+}
+
+// FreeSwap is the Swap: line of free.
+type FreeSwap struct {
+	Total float64 `json:"total"`
+	Used  float64 `json:"used"`
+	Free  float64 `json:"free"`
+}
+
+// Free is the output of free, in the unit free was asked for (MiB for -m).
+type Free struct {
+	Mem  FreeMemory `json:"mem"`
+	Swap FreeSwap   `json:"swap"`
+}
+
+// ParseFree parses the output of free. Older procps versions that print
+// separate buffers and cached columns are summed into BuffCache.
+func ParseFree(output string) (*Free, error) {
+	var t *table
+	f := &Free{}
+	seen := false
+	for _, line := range lines(output) {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "total":
+			t = &table{header: fields}
+		case t != nil && fields[0] == "Mem:":
+			row := fields[1:]
+			f.Mem = FreeMemory{
+				Total:     t.num(row, "total"),
+				Used:      t.num(row, "used"),
+				Free:      t.num(row, "free"),
+				Shared:    t.num(row, "shared"),
+				BuffCache: t.num(row, "buff/cache") + t.num(row, "buffers") + t.num(row, "cached"),
+				Available: t.num(row, "available"),
+			}
+			seen = true
+		case t != nil && fields[0] == "Swap:":
+			row := fields[1:]
+			f.Swap = FreeSwap{
+				Total: t.num(row, "total"),
+				Used:  t.num(row, "used"),
+				Free:  t.num(row, "free"),
+			}
+		}
+	}
+	if !seen {
+		return nil, fmt.Errorf("no Mem: line in free output")
+	}
+	return f, nil
+}
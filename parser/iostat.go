@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IostatCPU is the avg-cpu section of an iostat report.
+type IostatCPU struct {
+	User   float64 `json:"user"`
+	Nice   float64 `json:"nice"`
+	System float64 `json:"system"`
+	Iowait float64 `json:"iowait"`
+	Steal  float64 `json:"steal"`
+	Idle   float64 `json:"idle"`
+}
+
+// IostatDevice is the extended statistics of one device.
+type IostatDevice struct {
+	Device  string  `json:"device"`
+	RPerS   float64 `json:"r_per_s"`
+	WPerS   float64 `json:"w_per_s"`
+	RKBPerS float64 `json:"rkb_per_s"`
+	WKBPerS float64 `json:"wkb_per_s"`
+	RAwait  float64 `json:"r_await"` // ms
+	WAwait  float64 `json:"w_await"` // ms
+	Await   float64 `json:"await"`   // ms, reads and writes combined
+	AquSz   float64 `json:"aqu_sz"`  // Average queue length
+	Util    float64 `json:"util"`    // % of time the device was busy
+}
+
+// IostatReport is one interval of iostat output.
+type IostatReport struct {
+	CPU     *IostatCPU     `json:"cpu,omitempty"`
+	Devices []IostatDevice `json:"devices"`
+}
+
+// Iostat is the output of iostat -x. The first report covers the time since
+// boot and is kept apart from the interval reports (iostat -y leaves it out,
+// so its first interval is taken for it).
+type Iostat struct {
+	SinceBoot *IostatReport  `json:"since_boot,omitempty"`
+	Reports   []IostatReport `json:"reports"`
+}
+
+// ParseIostat parses the output of iostat -x on Linux, for both the current
+// (r_await, aqu-sz) and the older (await, avgqu-sz) column sets.
+func ParseIostat(output string) (*Iostat, error) {
+	s := &Iostat{}
+	var cur *IostatReport
+	var t *table
+	section := ""
+	report := func() *IostatReport {
+		if cur == nil {
+			s.Reports = append(s.Reports, IostatReport{})
+			cur = &s.Reports[len(s.Reports)-1]
+		}
+		return cur
+	}
+	for _, line := range lines(output) {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			section = ""
+		case fields[0] == "avg-cpu:":
+			// A new report starts with the CPU section
+			cur = nil
+			section = "cpu"
+			t = &table{header: fields[1:]}
+		case fields[0] == "Device" || fields[0] == "Device:":
+			if cur != nil && len(cur.Devices) > 0 {
+				cur = nil
+			}
+			section = "device"
+			t = &table{header: fields[1:]}
+		case section == "cpu" && isNumber(fields[0]):
+			report().CPU = &IostatCPU{
+				User:   t.num(fields, "%user"),
+				Nice:   t.num(fields, "%nice"),
+				System: t.num(fields, "%system"),
+				Iowait: t.num(fields, "%iowait"),
+				Steal:  t.num(fields, "%steal"),
+				Idle:   t.num(fields, "%idle"),
+			}
+		case section == "device":
+			row := fields[1:]
+			d := IostatDevice{
+				Device:  fields[0],
+				RPerS:   t.num(row, "r/s"),
+				WPerS:   t.num(row, "w/s"),
+				RKBPerS: t.num(row, "rkB/s"),
+				WKBPerS: t.num(row, "wkB/s"),
+				RAwait:  t.num(row, "r_await"),
+				WAwait:  t.num(row, "w_await"),
+				Await:   t.num(row, "await"),
+				AquSz:   t.num(row, "aqu-sz", "avgqu-sz"),
+				Util:    t.num(row, "%util"),
+			}
+			if t.col("await") < 0 {
+				// Newer sysstat drops the combined column; weight it by operations
+				if ops := d.RPerS + d.WPerS; ops > 0 {
+					d.Await = (d.RAwait*d.RPerS + d.WAwait*d.WPerS) / ops
+				}
+			}
+			r := report()
+			r.Devices = append(r.Devices, d)
+		}
+	}
+	if len(s.Reports) == 0 {
+		return nil, fmt.Errorf("no report in iostat output")
+	}
+	s.SinceBoot = &s.Reports[0]
+	s.Reports = s.Reports[1:]
+	return s, nil
+}
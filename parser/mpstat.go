@@ -0,0 +1,63 @@
+package parser
+
+import "fmt"
+
+// MpstatRow is the CPU breakdown of one CPU ("all" for the total) over one
+// interval.
+type MpstatRow struct {
+	Time   string  `json:"time"` // "Average" for the summary rows
+	CPU    string  `json:"cpu"`
+	Usr    float64 `json:"usr"`
+	Nice   float64 `json:"nice"`
+	Sys    float64 `json:"sys"`
+	Iowait float64 `json:"iowait"`
+	Irq    float64 `json:"irq"`
+	Soft   float64 `json:"soft"`
+	Steal  float64 `json:"steal"`
+	Guest  float64 `json:"guest"`
+	Gnice  float64 `json:"gnice"`
+	Idle   float64 `json:"idle"`
+}
+
+// Mpstat is the output of mpstat -P ALL.
+type Mpstat struct {
+	Samples []MpstatRow `json:"samples"`
+	Average []MpstatRow `json:"average,omitempty"`
+}
+
+// ParseMpstat parses the CPU utilization tables printed by mpstat.
+func ParseMpstat(output string) (*Mpstat, error) {
+	m := &Mpstat{}
+	found := false
+	for _, t := range sysstatTables(output) {
+		if !t.hasColumn("%idle") || !t.hasColumn("CPU") {
+			continue
+		}
+		found = true
+		for i, row := range t.rows {
+			r := MpstatRow{
+				Time:   t.times[i],
+				CPU:    t.str(row, "CPU"),
+				Usr:    t.num(row, "%usr", "%user"),
+				Nice:   t.num(row, "%nice"),
+				Sys:    t.num(row, "%sys", "%system"),
+				Iowait: t.num(row, "%iowait"),
+				Irq:    t.num(row, "%irq"),
+				Soft:   t.num(row, "%soft"),
+				Steal:  t.num(row, "%steal"),
+				Guest:  t.num(row, "%guest"),
+				Gnice:  t.num(row, "%gnice"),
+				Idle:   t.num(row, "%idle"),
+			}
+			if r.Time == "Average" {
+				m.Average = append(m.Average, r)
+			} else {
+				m.Samples = append(m.Samples, r)
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no CPU table in mpstat output")
+	}
+	return m, nil
+}
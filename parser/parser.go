@@ -0,0 +1,139 @@
+// Package parser turns the text output of the classic 60-second analysis
+// tools into typed values.
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Func parses the stdout of a command.
+type Func func(output string) (any, error)
+
+var parsers = map[string]Func{
+	"uptime":  func(s string) (any, error) { return ParseUptime(s) },
+	"vmstat":  func(s string) (any, error) { return ParseVmstat(s) },
+	"mpstat":  func(s string) (any, error) { return ParseMpstat(s) },
+	"pidstat": func(s string) (any, error) { return ParsePidstat(s) },
+	"iostat":  func(s string) (any, error) { return ParseIostat(s) },
+	"free":    func(s string) (any, error) { return ParseFree(s) },
+	"sar-dev": func(s string) (any, error) { return ParseSarDev(s) },
+	"sar-tcp": func(s string) (any, error) { return ParseSarTCP(s) },
+	"top":     func(s string) (any, error) { return ParseTop(s) },
+}
+
+// results holds a zero value of the type each parser returns.
+var results = map[string]any{
+	"uptime":  Uptime{},
+	"vmstat":  Vmstat{},
+	"mpstat":  Mpstat{},
+	"pidstat": Pidstat{},
+	"iostat":  Iostat{},
+	"free":    Free{},
+	"sar-dev": SarDev{},
+	"sar-tcp": SarTCP{},
+	"top":     Top{},
+}
+
+// Result returns a zero value of the type the parser registered under name
+// returns, so that field names can be checked before anything is parsed.
+func Result(name string) (any, bool) {
+	v, ok := results[name]
+	return v, ok
+}
+
+// Lookup returns the parser registered under name.
+func Lookup(name string) (Func, bool) {
+	f, ok := parsers[name]
+	return f, ok
+}
+
+// Names lists the registered parsers.
+func Names() []string {
+	names := make([]string, 0, len(parsers))
+	for n := range parsers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse runs the parser registered under name on output.
+func Parse(name, output string) (any, error) {
+	f, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown parser %q", name)
+	}
+	return f(output)
+}
+
+// table is a block of whitespace-separated columns under a header line.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// col returns the index of the first of names present in the header, or -1.
+func (t *table) col(names ...string) int {
+	for _, n := range names {
+		for i, h := range t.header {
+			if strings.EqualFold(h, n) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// num returns the numeric value of the first of names present in row.
+func (t *table) num(row []string, names ...string) float64 {
+	i := t.col(names...)
+	if i < 0 || i >= len(row) {
+		return 0
+	}
+	return parseFloat(row[i])
+}
+
+// str returns the value of the first of names present in row.
+func (t *table) str(row []string, names ...string) string {
+	i := t.col(names...)
+	if i < 0 || i >= len(row) {
+		return ""
+	}
+	return row[i]
+}
+
+// rest joins row from the column of name to the end, for trailing columns
+// such as a command line that may contain spaces.
+func (t *table) rest(row []string, name string) string {
+	i := t.col(name)
+	if i < 0 || i >= len(row) {
+		return ""
+	}
+	return strings.Join(row[i:], " ")
+}
+
+// parseFloat parses a number, accepting a decimal comma as printed under some
+// locales. Malformed numbers yield 0.
+func parseFloat(s string) float64 {
+	s = strings.TrimSuffix(s, "%")
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	f, _ := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+	return f
+}
+
+// isNumber reports whether s parses as a number.
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+	return err == nil
+}
+
+// lines splits output into lines without trailing carriage returns.
+func lines(output string) []string {
+	ls := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	return ls
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected JSON in testdata")
+
+// TestGolden parses every testdata/<parser>.<case>.txt with the parser it is
+// named after and compares the result with <parser>.<case>.json.
+func TestGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	covered := map[string]bool{}
+	for _, in := range inputs {
+		name := strings.TrimSuffix(filepath.Base(in), ".txt")
+		parser, _, _ := strings.Cut(name, ".")
+		covered[parser] = true
+		t.Run(name, func(t *testing.T) {
+			output, err := os.ReadFile(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := Parse(parser, string(output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r, ok := Result(parser); !ok || reflect.TypeOf(v).Elem() != reflect.TypeOf(r) {
+				t.Errorf("Result(%s) = %T; want the type Parse returns, %T", parser, r, v)
+			}
+			got, err := json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+			golden := strings.TrimSuffix(in, ".txt") + ".json"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("%s parsed to\n%s\nwant\n%s", in, got, want)
+			}
+		})
+	}
+	for _, n := range Names() {
+		if !covered[n] {
+			t.Errorf("no testdata for parser %s", n)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, n := range Names() {
+		if _, err := Parse(n, "command not found\n"); err == nil {
+			t.Errorf("%s accepted output without its table", n)
+		}
+	}
+	if _, err := Parse("nope", ""); err == nil {
+		t.Error("unknown parser accepted")
+	}
+}
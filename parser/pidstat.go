@@ -0,0 +1,58 @@
+package parser
+
+import "fmt"
+
+// PidstatRow is the CPU usage of one task over one interval.
+type PidstatRow struct {
+	Time    string  `json:"time"` // "Average" for the summary rows
+	UID     string  `json:"uid"`
+	PID     string  `json:"pid"`
+	Usr     float64 `json:"usr"`
+	System  float64 `json:"system"`
+	Guest   float64 `json:"guest"`
+	Wait    float64 `json:"wait"`
+	CPU     float64 `json:"cpu_percent"` // %CPU
+	CPUID   string  `json:"cpu"`         // Processor the task last ran on
+	Command string  `json:"command"`
+}
+
+// Pidstat is the output of pidstat.
+type Pidstat struct {
+	Samples []PidstatRow `json:"samples"`
+	Average []PidstatRow `json:"average,omitempty"`
+}
+
+// ParsePidstat parses the CPU statistics tables printed by pidstat.
+func ParsePidstat(output string) (*Pidstat, error) {
+	p := &Pidstat{}
+	found := false
+	for _, t := range sysstatTables(output) {
+		if !t.hasColumn("PID") || !t.hasColumn("%CPU") {
+			continue
+		}
+		found = true
+		for i, row := range t.rows {
+			r := PidstatRow{
+				Time:    t.times[i],
+				UID:     t.str(row, "UID"),
+				PID:     t.str(row, "PID"),
+				Usr:     t.num(row, "%usr"),
+				System:  t.num(row, "%system"),
+				Guest:   t.num(row, "%guest"),
+				Wait:    t.num(row, "%wait"),
+				CPU:     t.num(row, "%CPU"),
+				CPUID:   t.str(row, "CPU"),
+				Command: t.rest(row, "Command"),
+			}
+			if r.Time == "Average" {
+				p.Average = append(p.Average, r)
+			} else {
+				p.Samples = append(p.Samples, r)
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no task table in pidstat output")
+	}
+	return p, nil
+}
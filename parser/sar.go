@@ -0,0 +1,128 @@
+package parser
+
+import "fmt"
+
+// SarDevRow is the traffic of one network interface over one interval.
+type SarDevRow struct {
+	Time       string  `json:"time"` // "Average" for the summary rows
+	Iface      string  `json:"iface"`
+	RxPckPerS  float64 `json:"rxpck_per_s"`
+	TxPckPerS  float64 `json:"txpck_per_s"`
+	RxKBPerS   float64 `json:"rxkb_per_s"`
+	TxKBPerS   float64 `json:"txkb_per_s"`
+	RxCmpPerS  float64 `json:"rxcmp_per_s"`
+	TxCmpPerS  float64 `json:"txcmp_per_s"`
+	RxMcstPerS float64 `json:"rxmcst_per_s"`
+	IfUtil     float64 `json:"ifutil"`
+}
+
+// SarDev is the output of sar -n DEV.
+type SarDev struct {
+	Samples []SarDevRow `json:"samples"`
+	Average []SarDevRow `json:"average,omitempty"`
+}
+
+// ParseSarDev parses the interface tables printed by sar -n DEV.
+func ParseSarDev(output string) (*SarDev, error) {
+	d := &SarDev{}
+	found := false
+	for _, t := range sysstatTables(output) {
+		if !t.hasColumn("IFACE") {
+			continue
+		}
+		found = true
+		for i, row := range t.rows {
+			r := SarDevRow{
+				Time:       t.times[i],
+				Iface:      t.str(row, "IFACE"),
+				RxPckPerS:  t.num(row, "rxpck/s"),
+				TxPckPerS:  t.num(row, "txpck/s"),
+				RxKBPerS:   t.num(row, "rxkB/s"),
+				TxKBPerS:   t.num(row, "txkB/s"),
+				RxCmpPerS:  t.num(row, "rxcmp/s"),
+				TxCmpPerS:  t.num(row, "txcmp/s"),
+				RxMcstPerS: t.num(row, "rxmcst/s"),
+				IfUtil:     t.num(row, "%ifutil"),
+			}
+			if r.Time == "Average" {
+				d.Average = append(d.Average, r)
+			} else {
+				d.Samples = append(d.Samples, r)
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no IFACE table in sar output")
+	}
+	return d, nil
+}
+
+// SarTCPRow is the TCP activity over one interval, combining the TCP and
+// ETCP tables printed for the same time.
+type SarTCPRow struct {
+	Time        string  `json:"time"`          // "Average" for the summary row
+	ActivePerS  float64 `json:"active_per_s"`  // Locally initiated connections
+	PassivePerS float64 `json:"passive_per_s"` // Remotely initiated connections
+	ISegPerS    float64 `json:"iseg_per_s"`
+	OSegPerS    float64 `json:"oseg_per_s"`
+	AtmptfPerS  float64 `json:"atmptf_per_s"` // Failed connection attempts
+	EstresPerS  float64 `json:"estres_per_s"` // Resets from established state
+	RetransPerS float64 `json:"retrans_per_s"`
+	ISegErrPerS float64 `json:"isegerr_per_s"`
+	ORstsPerS   float64 `json:"orsts_per_s"`
+}
+
+// SarTCP is the output of sar -n TCP,ETCP.
+type SarTCP struct {
+	Samples []SarTCPRow `json:"samples"`
+	Average *SarTCPRow  `json:"average,omitempty"`
+}
+
+// ParseSarTCP parses the TCP and ETCP tables printed by sar -n TCP,ETCP.
+// Either table may be missing if only one keyword was requested.
+func ParseSarTCP(output string) (*SarTCP, error) {
+	s := &SarTCP{}
+	byTime := map[string]*SarTCPRow{}
+	var order []string
+	row := func(time string) *SarTCPRow {
+		if r, ok := byTime[time]; ok {
+			return r
+		}
+		r := &SarTCPRow{Time: time}
+		byTime[time] = r
+		order = append(order, time)
+		return r
+	}
+	for _, t := range sysstatTables(output) {
+		switch {
+		case t.hasColumn("active/s"):
+			for i, f := range t.rows {
+				r := row(t.times[i])
+				r.ActivePerS = t.num(f, "active/s")
+				r.PassivePerS = t.num(f, "passive/s")
+				r.ISegPerS = t.num(f, "iseg/s")
+				r.OSegPerS = t.num(f, "oseg/s")
+			}
+		case t.hasColumn("retrans/s"):
+			for i, f := range t.rows {
+				r := row(t.times[i])
+				r.AtmptfPerS = t.num(f, "atmptf/s")
+				r.EstresPerS = t.num(f, "estres/s")
+				r.RetransPerS = t.num(f, "retrans/s")
+				r.ISegErrPerS = t.num(f, "isegerr/s")
+				r.ORstsPerS = t.num(f, "orsts/s")
+			}
+		}
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("no TCP or ETCP table in sar output")
+	}
+	for _, time := range order {
+		if time == "Average" {
+			s.Average = byTime[time]
+		} else {
+			s.Samples = append(s.Samples, *byTime[time])
+		}
+	}
+	return s, nil
+}
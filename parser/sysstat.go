@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+var clockRe = regexp.MustCompile(`^\d{1,2}:\d{2}:\d{2}$`)
+
+// sysstatRow is a row of mpstat, pidstat or sar output with its time column
+// split off. Average rows have Time "Average".
+type sysstatRow struct {
+	Time   string
+	Fields []string
+}
+
+// sysstatTable is a block of sysstat output under one header.
+type sysstatTable struct {
+	table
+	times []string // time of each row
+}
+
+// splitTime separates the leading time column ("10:15:01", "10:15:01 AM" or
+// "Average:") from fields.
+func splitTime(fields []string) (string, []string, bool) {
+	if len(fields) == 0 {
+		return "", nil, false
+	}
+	if fields[0] == "Average:" {
+		return "Average", fields[1:], true
+	}
+	if !clockRe.MatchString(fields[0]) {
+		return "", nil, false
+	}
+	if len(fields) > 1 && (fields[1] == "AM" || fields[1] == "PM") {
+		return fields[0] + " " + fields[1], fields[2:], true
+	}
+	return fields[0], fields[1:], true
+}
+
+// sysstatTables splits the output of mpstat, pidstat or sar into tables.
+// sysstat prints a banner line, then blocks separated by blank lines, each
+// starting with a header that carries the time column like the rows do.
+func sysstatTables(output string) []*sysstatTable {
+	var tables []*sysstatTable
+	var cur *sysstatTable
+	for _, line := range lines(output) {
+		if strings.TrimSpace(line) == "" {
+			cur = nil
+			continue
+		}
+		t, fields, ok := splitTime(strings.Fields(line))
+		if !ok || len(fields) == 0 {
+			// Banner or anything else that is not part of a table
+			cur = nil
+			continue
+		}
+		if cur == nil || isHeader(fields, cur) {
+			cur = &sysstatTable{table: table{header: fields}}
+			tables = append(tables, cur)
+			continue
+		}
+		cur.rows = append(cur.rows, fields)
+		cur.times = append(cur.times, t)
+	}
+	return tables
+}
+
+// isHeader reports whether fields start a new table inside the current
+// block, which happens when sar prints a new header without a blank line.
+func isHeader(fields []string, cur *sysstatTable) bool {
+	if len(fields) != len(cur.header) {
+		return false
+	}
+	for i, f := range fields {
+		if f != cur.header[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasColumn reports whether t has a column named name.
+func (t *sysstatTable) hasColumn(name string) bool {
+	return t.col(name) >= 0
+}
@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TopTasks is the Tasks: line of top.
+type TopTasks struct {
+	Total    int `json:"total"`
+	Running  int `json:"running"`
+	Sleeping int `json:"sleeping"`
+	Stopped  int `json:"stopped"`
+	Zombie   int `json:"zombie"`
+}
+
+// TopCPU is the %Cpu(s): line of top.
+type TopCPU struct {
+	User    float64 `json:"us"`
+	System  float64 `json:"sy"`
+	Nice    float64 `json:"ni"`
+	Idle    float64 `json:"id"`
+	Iowait  float64 `json:"wa"`
+	Irq     float64 `json:"hi"`
+	SoftIrq float64 `json:"si"`
+	Steal   float64 `json:"st"`
+}
+
+// TopMemory is the Mem and Swap lines of top, in Unit.
+type TopMemory struct {
+	Unit      string  `json:"unit"` // "KiB", "MiB", ...
+	Total     float64 `json:"total"`
+	Free      float64 `json:"free"`
+	Used      float64 `json:"used"`
+	BuffCache float64 `json:"buff_cache"`
+	SwapTotal float64 `json:"swap_total"`
+	SwapFree  float64 `json:"swap_free"`
+	SwapUsed  float64 `json:"swap_used"`
+	Available float64 `json:"available"`
+}
+
+// TopProcess is one row of the process table.
+type TopProcess struct {
+	PID     string  `json:"pid"`
+	User    string  `json:"user"`
+	PR      string  `json:"pr"`
+	NI      string  `json:"ni"`
+	Virt    string  `json:"virt"`
+	Res     string  `json:"res"`
+	Shr     string  `json:"shr"`
+	State   string  `json:"state"`
+	CPU     float64 `json:"cpu"`
+	Mem     float64 `json:"mem"`
+	Time    string  `json:"time"`
+	Command string  `json:"command"`
+}
+
+// Top is the first iteration of top -b output.
+type Top struct {
+	Uptime    *Uptime      `json:"uptime,omitempty"`
+	Tasks     TopTasks     `json:"tasks"`
+	CPU       TopCPU       `json:"cpu"`
+	Memory    TopMemory    `json:"memory"`
+	Processes []TopProcess `json:"processes"`
+}
+
+// ParseTop parses the first iteration of procps top in batch mode.
+func ParseTop(output string) (*Top, error) {
+	top := &Top{}
+	var t *table
+	iterations := 0
+	for _, line := range lines(output) {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "top - "):
+			iterations++
+			if iterations > 1 {
+				return top, nil
+			}
+			top.Uptime, _ = ParseUptime(strings.TrimPrefix(trimmed, "top - "))
+		case strings.HasPrefix(trimmed, "Tasks:"):
+			v := keyedValues(strings.TrimPrefix(trimmed, "Tasks:"))
+			top.Tasks = TopTasks{
+				Total:    int(v["total"]),
+				Running:  int(v["running"]),
+				Sleeping: int(v["sleeping"]),
+				Stopped:  int(v["stopped"]),
+				Zombie:   int(v["zombie"]),
+			}
+		case strings.HasPrefix(trimmed, "%Cpu"):
+			_, rest, _ := strings.Cut(trimmed, ":")
+			v := keyedValues(rest)
+			top.CPU = TopCPU{
+				User:    v["us"],
+				System:  v["sy"],
+				Nice:    v["ni"],
+				Idle:    v["id"],
+				Iowait:  v["wa"],
+				Irq:     v["hi"],
+				SoftIrq: v["si"],
+				Steal:   v["st"],
+			}
+		case strings.Contains(trimmed, " Mem :") || strings.Contains(trimmed, " Mem:"):
+			unit, rest, _ := strings.Cut(trimmed, ":")
+			v := keyedValues(rest)
+			top.Memory.Unit = strings.Fields(unit)[0]
+			top.Memory.Total = v["total"]
+			top.Memory.Free = v["free"]
+			top.Memory.Used = v["used"]
+			top.Memory.BuffCache = v["buff/cache"]
+		case strings.Contains(trimmed, " Swap:"):
+			_, rest, _ := strings.Cut(trimmed, ":")
+			v := keyedValues(rest)
+			top.Memory.SwapTotal = v["total"]
+			top.Memory.SwapFree = v["free"]
+			top.Memory.SwapUsed = v["used"]
+			top.Memory.Available = v["avail"]
+		case strings.HasPrefix(trimmed, "PID "):
+			t = &table{header: strings.Fields(trimmed)}
+		case t != nil && trimmed != "":
+			row := strings.Fields(trimmed)
+			top.Processes = append(top.Processes, TopProcess{
+				PID:     t.str(row, "PID"),
+				User:    t.str(row, "USER"),
+				PR:      t.str(row, "PR"),
+				NI:      t.str(row, "NI"),
+				Virt:    t.str(row, "VIRT"),
+				Res:     t.str(row, "RES"),
+				Shr:     t.str(row, "SHR"),
+				State:   t.str(row, "S"),
+				CPU:     t.num(row, "%CPU"),
+				Mem:     t.num(row, "%MEM"),
+				Time:    t.str(row, "TIME+", "TIME"),
+				Command: t.rest(row, "COMMAND"),
+			})
+		}
+	}
+	if iterations == 0 {
+		return nil, fmt.Errorf("no top header found")
+	}
+	return top, nil
+}
+
+// keyedValues parses the "1.2 us,  0.5 sy" style of the top summary lines
+// into a map from key to value. "13100.2 avail Mem" is keyed "avail".
+func keyedValues(s string) map[string]float64 {
+	v := map[string]float64{}
+	f := strings.Fields(s)
+	for i := 0; i+1 < len(f); i++ {
+		if isNumber(f[i]) {
+			v[strings.TrimRight(f[i+1], ",.")] = parseFloat(f[i])
+			i++
+		}
+	}
+	return v
+}
@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Uptime is the output of uptime, and the first line of top.
+type Uptime struct {
+	Time   string  `json:"time"`
+	Up     string  `json:"up"` // As printed, e.g. "12 days,  3:04"
+	Users  int     `json:"users"`
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+}
+
+var (
+	uptimeUsersRe = regexp.MustCompile(`,\s*(\d+)\s+users?`)
+	uptimeLoadRe  = regexp.MustCompile(`load averages?:\s*([\d.,]+)[,\s]+([\d.,]+)[,\s]+([\d.,]+)`)
+)
+
+// ParseUptime parses the output of uptime on Linux and macOS.
+func ParseUptime(output string) (*Uptime, error) {
+	line := strings.TrimSpace(output)
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	m := uptimeLoadRe.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("no load average in uptime output")
+	}
+	u := &Uptime{
+		Load1:  parseFloat(strings.TrimSuffix(m[1], ",")),
+		Load5:  parseFloat(strings.TrimSuffix(m[2], ",")),
+		Load15: parseFloat(strings.TrimSuffix(m[3], ",")),
+	}
+	if f := strings.Fields(line); len(f) > 0 {
+		u.Time = f[0]
+	}
+	rest := line
+	if i := strings.Index(rest, " up "); i >= 0 {
+		rest = rest[i+len(" up "):]
+		end := strings.Index(rest, "load average")
+		if loc := uptimeUsersRe.FindStringIndex(rest); loc != nil {
+			end = loc[0]
+		}
+		if end >= 0 {
+			u.Up = strings.TrimRight(strings.TrimSpace(rest[:end]), ",")
+		}
+	}
+	if m := uptimeUsersRe.FindStringSubmatch(line); m != nil {
+		u.Users, _ = strconv.Atoi(m[1])
+	}
+	return u, nil
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VmstatSample is one line of vmstat output. Memory is in the unit vmstat
+// was asked for (KiB by default).
+type VmstatSample struct {
+	R     float64 `json:"r"`     // Runnable tasks
+	B     float64 `json:"b"`     // Tasks blocked on I/O
+	Swpd  float64 `json:"swpd"`  // Swap used
+	Free  float64 `json:"free"`  // Idle memory
+	Buff  float64 `json:"buff"`  // Buffers
+	Cache float64 `json:"cache"` // Page cache
+	Si    float64 `json:"si"`    // Swapped in per second
+	So    float64 `json:"so"`    // Swapped out per second
+	Bi    float64 `json:"bi"`    // Blocks read per second
+	Bo    float64 `json:"bo"`    // Blocks written per second
+	In    float64 `json:"in"`    // Interrupts per second
+	Cs    float64 `json:"cs"`    // Context switches per second
+	Us    float64 `json:"us"`    // % user time
+	Sy    float64 `json:"sy"`    // % system time
+	Id    float64 `json:"id"`    // % idle
+	Wa    float64 `json:"wa"`    // % waiting for I/O
+	St    float64 `json:"st"`    // % stolen by the hypervisor
+}
+
+// Vmstat is the output of vmstat. The first line vmstat prints reports
+// averages since boot and is kept apart from the interval samples (vmstat -y
+// leaves it out, so its first interval is taken for it).
+type Vmstat struct {
+	SinceBoot *VmstatSample  `json:"since_boot,omitempty"`
+	Samples   []VmstatSample `json:"samples"`
+}
+
+// ParseVmstat parses the output of Linux vmstat. Header lines repeated by
+// long runs are skipped.
+func ParseVmstat(output string) (*Vmstat, error) {
+	var t *table
+	v := &Vmstat{}
+	for _, line := range lines(output) {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0 || strings.HasPrefix(fields[0], "procs"):
+		case fields[0] == "r":
+			t = &table{header: fields}
+		case t != nil && isNumber(fields[0]):
+			sample := VmstatSample{
+				R:     t.num(fields, "r"),
+				B:     t.num(fields, "b"),
+				Swpd:  t.num(fields, "swpd"),
+				Free:  t.num(fields, "free"),
+				Buff:  t.num(fields, "buff"),
+				Cache: t.num(fields, "cache"),
+				Si:    t.num(fields, "si"),
+				So:    t.num(fields, "so"),
+				Bi:    t.num(fields, "bi"),
+				Bo:    t.num(fields, "bo"),
+				In:    t.num(fields, "in"),
+				Cs:    t.num(fields, "cs"),
+				Us:    t.num(fields, "us"),
+				Sy:    t.num(fields, "sy"),
+				Id:    t.num(fields, "id"),
+				Wa:    t.num(fields, "wa"),
+				St:    t.num(fields, "st"),
+			}
+			if v.SinceBoot == nil {
+				v.SinceBoot = &sample
+			} else {
+				v.Samples = append(v.Samples, sample)
+			}
+		}
+	}
+	if t == nil {
+		return nil, fmt.Errorf("no vmstat header found")
+	}
+	return v, nil
+}
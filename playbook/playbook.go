@@ -9,10 +9,70 @@ type PlaybookConfig struct {
 	} `yaml:"nixpkgs"`
 	SystemPrompt string            `yaml:"system_prompt,omitempty"`
 	Commands     []PlaybookCommand `yaml:"commands"`
+	Rules        []Rule            `yaml:"rules,omitempty"`  // Added to the built-in rules; a rule with a built-in id replaces it
+	LLM          LLMConfig         `yaml:"llm,omitempty"`    // Overrides the user's config file; command line flags override both
+	Redact       Redaction         `yaml:"redact,omitempty"` // Patterns added to the redaction settings of the user's config file
 }
 
 type PlaybookCommand struct {
-	Command        string `yaml:"command"`
-	Description    string `yaml:"description"`
-	TimeoutSeconds int    `yaml:"timeout_seconds,omitempty"`
+	Command          string  `yaml:"command"`
+	Description      string  `yaml:"description"`
+	TimeoutSeconds   int     `yaml:"timeout_seconds,omitempty"`    // Kill the command and report it as timed out after this long
+	StopAfterSeconds int     `yaml:"stop_after_seconds,omitempty"` // Interrupt a streaming collector after this long and treat it as success
+	Resolve          string  `yaml:"resolve,omitempty"`            // toolbox-only, toolbox-then-host or host-only
+	Shell            bool    `yaml:"shell,omitempty"`              // Run through sh -c, allowing pipes, redirects and expansions
+	Package          string  `yaml:"package,omitempty"`            // nixpkgs package the binary must come from when several ship it
+	MaxLines         int     `yaml:"max_lines,omitempty"`          // Lines kept for the UI and summarizer (default 100, -1 for no limit)
+	MaxBytes         int     `yaml:"max_bytes,omitempty"`          // Bytes kept for the UI and summarizer (default 32 KiB, -1 for no limit)
+	Keep             string  `yaml:"keep,omitempty"`               // Which lines to keep: head, tail (default) or both
+	Parser           string  `yaml:"parser,omitempty"`             // Structured parser applied to stdout, e.g. vmstat or iostat
+	Checks           []Check `yaml:"checks,omitempty"`             // Expectations evaluated after the command finishes
+}
+
+// Check is an expectation about the output of a single command. Exactly one
+// of Expect, Match and NotMatch is set.
+type Check struct {
+	Expect   string `yaml:"expect,omitempty"`    // Condition over parsed fields that must hold, e.g. "mem.available > 500"
+	Match    string `yaml:"match,omitempty"`     // Regular expression stdout or stderr must match
+	NotMatch string `yaml:"not_match,omitempty"` // Regular expression stdout and stderr must not match
+	Severity string `yaml:"severity,omitempty"`  // warning (default) or critical
+	Message  string `yaml:"message"`             // May refer to {value}, {match} and variables such as {cpus}
+}
+
+// Rule turns command output into a finding. It applies to the commands
+// selected by Parser or Command and fires when When holds over the parsed
+// output or Match matches the raw output.
+type Rule struct {
+	ID       string `yaml:"id"`
+	Parser   string `yaml:"parser,omitempty"`   // Apply to commands using this parser
+	Command  string `yaml:"command,omitempty"`  // Apply to commands running this program
+	When     string `yaml:"when,omitempty"`     // Condition over parsed fields, e.g. "max(samples.r) > cpus"
+	Match    string `yaml:"match,omitempty"`    // Regular expression over stdout and stderr
+	Severity string `yaml:"severity,omitempty"` // info, warning (default) or critical; off disables the rule
+	Message  string `yaml:"message"`            // May refer to {value}, {threshold}, {match} and variables such as {cpus}
+}
+
+// LLMConfig selects the model that writes the summary. Unset fields are left
+// to the next layer: config file, then playbook, then command line flags.
+type LLMConfig struct {
+	Provider    string   `yaml:"provider,omitempty"`    // anthropic, openai, openrouter or local; empty picks one from the environment
+	Model       string   `yaml:"model,omitempty"`       // Defaults depend on the provider
+	BaseURL     string   `yaml:"base_url,omitempty"`    // API endpoint, e.g. a proxy or a local server; ignored in playbooks
+	MaxTokens   int      `yaml:"max_tokens,omitempty"`  // Upper bound on the length of the summary
+	Temperature *float64 `yaml:"temperature,omitempty"` // Sampling temperature; the provider's default if unset
+}
+
+// Redaction adjusts what is masked in command output before it is sent to
+// the LLM.
+type Redaction struct {
+	Disable  []string        `yaml:"disable,omitempty"`  // Built-in patterns to turn off, e.g. hostname; ignored in playbooks
+	Patterns []RedactPattern `yaml:"patterns,omitempty"` // Masked in addition to the built-in patterns
+}
+
+// RedactPattern is a regular expression whose matches are replaced by a
+// placeholder such as [customer-1]. If it has a capture group, only the
+// first group that matched is replaced.
+type RedactPattern struct {
+	Name  string `yaml:"name"`
+	Regex string `yaml:"regex"`
 }
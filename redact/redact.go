@@ -0,0 +1,250 @@
+// Package redact masks secrets and personal data in command output before it
+// leaves the machine. Every distinct value is replaced by the same
+// placeholder each time it occurs, so the text can still be correlated.
+package redact
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gradient-engineer/playbook"
+)
+
+// Pattern finds values of one kind.
+type Pattern struct {
+	Name   string         // Kind of value, used in placeholders such as [email-1]
+	Regexp *regexp.Regexp // If it has capture groups, only the first that matched is replaced
+	Valid  func(string) bool
+}
+
+// Builtin returns the built-in patterns, most specific first. hostname is the
+// name of this machine, which is masked wherever it appears.
+func Builtin(hostname string) []Pattern {
+	patterns := []Pattern{
+		{Name: "private-key", Regexp: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+		{Name: "jwt", Regexp: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]*`)},
+		{Name: "aws-key", Regexp: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA)[0-9A-Z]{16}\b`)},
+		{Name: "aws-key", Regexp: regexp.MustCompile(`(?i)aws_?secret_?access_?key\W{0,3}([A-Za-z0-9/+]{40})`)},
+		{Name: "api-key", Regexp: regexp.MustCompile(`\b(?:sk-(?:ant-|proj-|or-v1-)?[A-Za-z0-9_-]{20,}|gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}|glpat-[A-Za-z0-9_-]{20,}|xox[abprs]-[A-Za-z0-9-]{10,}|AIza[0-9A-Za-z_-]{35})`)},
+		// Credentials in URLs, key=value pairs and command line flags
+		{Name: "secret", Regexp: regexp.MustCompile(`://[^/\s:@]+:([^/\s@]+)@`)},
+		{Name: "secret", Regexp: regexp.MustCompile(`(?i)(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|auth)[\w.-]*\s*[=:]\s*["']?(?:bearer\s+)?([^\s"'&,;]{4,})`)},
+		{Name: "secret", Regexp: regexp.MustCompile(`(?i)\s--?(?:password|passwd|pass|secret|token|api-?key)\s+["']?([^\s"']+)`)},
+		{Name: "email", Regexp: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+		{Name: "ipv6", Regexp: regexp.MustCompile(`(?i)(?:^|[^\w:])([0-9a-f]*:[0-9a-f:]*:[0-9a-f]*(?:\.\d+){0,3}(?:%\w+)?)`), Valid: isIPv6},
+		{Name: "ipv4", Regexp: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), Valid: isIPv4},
+	}
+	if hostname != "" && hostname != "localhost" {
+		names := []string{regexp.QuoteMeta(hostname)}
+		if short, _, ok := strings.Cut(hostname, "."); ok && short != "" {
+			names = append(names, regexp.QuoteMeta(short))
+		}
+		patterns = append(patterns, Pattern{Name: "hostname", Regexp: regexp.MustCompile(`(?i)\b(?:` + strings.Join(names, "|") + `)\b`)})
+	}
+	// The whole dotted word is matched so that names such as config.local.yaml
+	// or snap.foo.app.service are not cut short at a TLD-like label
+	return append(patterns, Pattern{
+		Name:   "hostname",
+		Regexp: regexp.MustCompile(`(?:^|//|[^\w@/.-])([A-Za-z0-9][\w-]*(?:\.[\w-]+)+)`),
+		Valid:  isHostname,
+	})
+}
+
+// hostnameTLDs are the last labels that make a dotted word look like a host
+// rather than a file name.
+var hostnameTLDs = map[string]bool{
+	"com": true, "net": true, "org": true, "io": true, "dev": true, "app": true, "cloud": true,
+	"ai": true, "co": true, "info": true, "biz": true, "us": true, "uk": true, "de": true, "eu": true,
+	"internal": true, "local": true, "localdomain": true, "lan": true, "corp": true, "home": true,
+	"intra": true, "intranet": true, "private": true,
+}
+
+var hostnameLabel = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// isHostname accepts valid DNS names that end in one of hostnameTLDs.
+func isHostname(s string) bool {
+	labels := strings.Split(strings.ToLower(s), ".")
+	if !hostnameTLDs[labels[len(labels)-1]] {
+		return false
+	}
+	for _, l := range labels {
+		if !hostnameLabel.MatchString(l) {
+			return false
+		}
+	}
+	return true
+}
+
+// isIPv6 accepts addresses with at least two groups, which rules out times
+// and scope operators such as "d::".
+func isIPv6(s string) bool {
+	s, _, _ = strings.Cut(s, "%")
+	groups := strings.FieldsFunc(s, func(r rune) bool { return r == ':' })
+	ip := net.ParseIP(s)
+	return ip != nil && len(groups) >= 2 && !ip.IsLoopback() && !ip.IsUnspecified()
+}
+
+func isIPv4(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && !ip.IsLoopback() && !ip.IsUnspecified()
+}
+
+// Compile turns a user-supplied pattern into a Pattern.
+func Compile(p playbook.RedactPattern) (Pattern, error) {
+	re, err := regexp.Compile(p.Regex)
+	if err != nil {
+		return Pattern{}, fmt.Errorf("redact pattern %s: %w", p.Name, err)
+	}
+	return Pattern{Name: slug(p.Name), Regexp: re}, nil
+}
+
+// slug makes name safe to use in a placeholder.
+func slug(name string) string {
+	s := strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '-'
+		}
+	}, name), "-")
+	if s == "" {
+		return "redacted"
+	}
+	return s
+}
+
+// tokenPattern matches a placeholder. Values that contain one were already
+// replaced by an earlier pattern.
+var tokenPattern = regexp.MustCompile(`\[[a-z0-9-]+-\d+\]`)
+
+// Redactor replaces matches of its patterns with placeholders. It remembers
+// the placeholder given to each value and is safe for concurrent use.
+type Redactor struct {
+	patterns []Pattern
+
+	mu       sync.Mutex
+	tokens   map[string]string // Name and value to placeholder
+	values   map[string]string // Placeholder to value
+	counters map[string]int    // Values seen per name
+}
+
+// New builds a Redactor from the built-in patterns and the settings in cfgs,
+// applied in order. Custom patterns are tried before the built-in ones.
+func New(hostname string, cfgs ...playbook.Redaction) (*Redactor, error) {
+	disabled := map[string]bool{}
+	var custom []Pattern
+	for _, cfg := range cfgs {
+		for _, name := range cfg.Disable {
+			disabled[name] = true
+		}
+		for _, p := range cfg.Patterns {
+			c, err := Compile(p)
+			if err != nil {
+				return nil, err
+			}
+			custom = append(custom, c)
+		}
+	}
+	patterns := custom
+	for _, p := range Builtin(hostname) {
+		if !disabled[p.Name] {
+			patterns = append(patterns, p)
+		}
+	}
+	return &Redactor{
+		patterns: patterns,
+		tokens:   map[string]string{},
+		values:   map[string]string{},
+		counters: map[string]int{},
+	}, nil
+}
+
+// Redact returns s with every match replaced. A nil Redactor returns s
+// unchanged.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.patterns {
+		s = r.replace(s, p)
+	}
+	return s
+}
+
+func (r *Redactor) replace(s string, p Pattern) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range p.Regexp.FindAllStringSubmatchIndex(s, -1) {
+		start, end := m[0], m[1]
+		for g := 2; g < len(m); g += 2 {
+			if m[g] >= 0 && m[g] < m[g+1] {
+				start, end = m[g], m[g+1]
+				break
+			}
+		}
+		value := s[start:end]
+		if start < last || start == end || tokenPattern.MatchString(value) || (p.Valid != nil && !p.Valid(value)) {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(r.token(p.Name, value))
+		last = end
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// token returns the placeholder for value, allocating the next one for name
+// the first time value is seen.
+func (r *Redactor) token(name, value string) string {
+	key := name + "\x00" + value
+	if t, ok := r.tokens[key]; ok {
+		return t
+	}
+	r.counters[name]++
+	t := fmt.Sprintf("[%s-%d]", name, r.counters[name])
+	r.tokens[key] = t
+	r.values[t] = value
+	return t
+}
+
+// Restore puts the original values back in place of the placeholders in s,
+// e.g. in the LLM's reply. A nil Redactor returns s unchanged.
+func (r *Redactor) Restore(s string) string {
+	if r == nil {
+		return s
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return tokenPattern.ReplaceAllStringFunc(s, func(t string) string {
+		if v, ok := r.values[t]; ok {
+			return v
+		}
+		return t
+	})
+}
+
+// Counts returns how many distinct values of each kind were replaced.
+func (r *Redactor) Counts() map[string]int {
+	counts := map[string]int{}
+	if r == nil {
+		return counts
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, n := range r.counters {
+		counts[name] = n
+	}
+	return counts
+}
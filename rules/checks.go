@@ -0,0 +1,122 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+
+	"gradient-engineer/playbook"
+)
+
+// CheckRule is the rule name of findings produced by failed checks.
+const CheckRule = "check"
+
+// Check is a compiled playbook check of a single command.
+type Check struct {
+	playbook.Check
+	parser string
+	cond   *Condition
+	re     *regexp.Regexp
+}
+
+// CompileChecks compiles the checks of a command whose output is parsed by
+// parser (empty if it is not parsed). Fields in expect conditions must exist
+// in that parser's result.
+func CompileChecks(parser string, checks []playbook.Check) ([]*Check, error) {
+	var out []*Check
+	for i, pc := range checks {
+		c := &Check{Check: pc, parser: parser}
+		set := 0
+		for _, s := range []string{pc.Expect, pc.Match, pc.NotMatch} {
+			if s != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("check %d: needs exactly one of expect, match or not_match", i+1)
+		}
+		switch pc.Severity {
+		case "":
+			c.Severity = SeverityWarning
+		case SeverityWarning, SeverityCritical:
+		default:
+			return nil, fmt.Errorf("check %d: unknown severity %q (want warning or critical)", i+1, pc.Severity)
+		}
+		var err error
+		switch {
+		case pc.Expect != "":
+			if parser == "" {
+				return nil, fmt.Errorf("check %d: expect needs a parser on the command", i+1)
+			}
+			if c.cond, err = ParseCondition(pc.Expect); err != nil {
+				return nil, fmt.Errorf("check %d: %w", i+1, err)
+			}
+			if err := c.cond.checkFields(parser); err != nil {
+				return nil, fmt.Errorf("check %d: %w", i+1, err)
+			}
+		case pc.Match != "":
+			c.re, err = regexp.Compile(pc.Match)
+		default:
+			c.re, err = regexp.Compile(pc.NotMatch)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("check %d: invalid regular expression: %w", i+1, err)
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// Evaluate reports whether the check fails for in, and the finding
+// describing the failure if it does. Fields of an expect condition may be
+// prefixed with the parser name, as in "free.mem.available"; a field over a
+// list fails the check if any of its values does, and {value} is the worst
+// of those.
+func (c *Check) Evaluate(in Input, vars map[string]float64) (Finding, bool) {
+	placeholders := map[string]string{}
+	switch {
+	case c.cond != nil:
+		data := Normalize(in.Parsed)
+		if m, ok := data.(map[string]any); ok {
+			scoped := map[string]any{c.parser: m}
+			for k, v := range m {
+				scoped[k] = v
+			}
+			data = scoped
+		}
+		// Unlike a rule, a check must hold for every sample
+		ok, v, known := c.cond.HoldsForAll(data, vars)
+		if ok {
+			return Finding{}, false
+		}
+		placeholders["value"] = "unknown"
+		if known {
+			placeholders["value"] = formatNumber(v)
+		}
+	case c.Match != "":
+		if c.re.MatchString(in.Output) {
+			return Finding{}, false
+		}
+	default:
+		loc := c.re.FindStringIndex(in.Output)
+		if loc == nil {
+			return Finding{}, false
+		}
+		placeholders["match"] = matchedLine(in.Output, loc)
+	}
+	msg := c.Message
+	switch {
+	case msg != "":
+	case c.Expect != "":
+		msg = "expected " + c.Expect + " (got {value})"
+	case c.Match != "":
+		msg = "output does not match " + c.Match
+	default:
+		msg = "output matches " + c.NotMatch + ": {match}"
+	}
+	return Finding{
+		Rule:     CheckRule,
+		Severity: c.Severity,
+		Message:  expand(msg, placeholders, vars),
+		Command:  in.Command,
+	}, true
+}
@@ -0,0 +1,85 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"gradient-engineer/parser"
+	"gradient-engineer/playbook"
+)
+
+func TestCheckEvaluate(t *testing.T) {
+	vmstat := &parser.Vmstat{Samples: []parser.VmstatSample{
+		{Id: 97, R: 1},
+		{Id: 12, R: 9},
+		{Id: 4, R: 3},
+	}}
+	free := &parser.Free{Mem: parser.FreeMemory{Available: 300}}
+
+	tests := []struct {
+		name   string
+		parser string
+		parsed any
+		expect string
+		fails  bool
+		want   string
+	}{
+		{"every sample satisfies", "vmstat", vmstat, "samples.id >= 4", false, ""},
+		{"one sample violates", "vmstat", vmstat, "samples.id > 10", true, "expected samples.id > 10 (got 4)"},
+		{"worst violation is reported", "vmstat", vmstat, "samples.r < 3", true, "expected samples.r < 3 (got 9)"},
+		{"aggregate", "vmstat", vmstat, "max(samples.r) <= 9", false, ""},
+		{"parser prefix", "free", free, "free.mem.available > 500", true, "expected free.mem.available > 500 (got 300)"},
+		{"variable", "vmstat", vmstat, "samples.r <= cpus", true, "expected samples.r <= cpus (got 9)"},
+		{"alternative holds", "vmstat", vmstat, "samples.id > 10 or samples.r < 10", false, ""},
+		{"every alternative fails", "vmstat", vmstat, "samples.id > 10 or samples.r < 5", true, "expected samples.id > 10 or samples.r < 5 (got 4)"},
+		{"no samples", "vmstat", &parser.Vmstat{}, "samples.r < 5", true, "expected samples.r < 5 (got unknown)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, err := CompileChecks(tt.parser, []playbook.Check{{Expect: tt.expect}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, failed := checks[0].Evaluate(Input{Command: "cmd", Parsed: tt.parsed}, map[string]float64{"cpus": 4})
+			if failed != tt.fails {
+				t.Fatalf("failed = %v; want %v", failed, tt.fails)
+			}
+			if f.Message != tt.want {
+				t.Errorf("message = %q; want %q", f.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileChecksFields(t *testing.T) {
+	tests := []struct {
+		parser string
+		expect string
+		want   string // Error; empty if the check compiles
+	}{
+		{"free", "mem.available > 500", ""},
+		{"free", "free.mem.available > 500", ""},
+		{"free", "free.available_mb > 500", `unknown field "free.available_mb" in free output`},
+		{"free", "mem.available_mb > 500", `unknown field "mem.available_mb" in free output`},
+		{"vmstat", "max(samples.r) <= cpus", ""},
+		{"vmstat", "count(samples) > 0", ""},
+		{"vmstat", "since_boot.r < 10", ""},
+		{"vmstat", "samples.nope > 1", `unknown field "samples.nope" in vmstat output`},
+		{"vmstat", "samples.r < samples.rr", `unknown field "samples.rr" in vmstat output`},
+		{"iostat", "reports.devices.util < 90", ""},
+		{"iostat", "reports.util < 90", `unknown field "reports.util" in iostat output`},
+		{"top", "uptime.load1 < 8", ""},
+		{"custom", "anything.at.all > 1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.parser+" "+tt.expect, func(t *testing.T) {
+			_, err := CompileChecks(tt.parser, []playbook.Check{{Expect: tt.expect}})
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("CompileChecks: %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("CompileChecks = %v; want %s", err, tt.want)
+			}
+		})
+	}
+}
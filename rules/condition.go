@@ -0,0 +1,439 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gradient-engineer/parser"
+)
+
+// Condition is a parsed rule condition: comparisons joined by "and" and
+// "or", where "and" binds tighter. Each side of a comparison is a number, a
+// dotted field path into parsed output, or an aggregate over one of max,
+// min, avg, sum or count. A path crossing a list yields one value per
+// element; a comparison holds if any of them satisfies it, or with
+// HoldsForAll only if all of them do.
+type Condition struct {
+	text string
+	or   [][]comparison // Disjunction of conjunctions
+}
+
+type comparison struct {
+	left, right operand
+	op          string
+}
+
+type operand struct {
+	fn   string   // Aggregate function, if any
+	path []string // Field path; nil for a number
+	num  float64
+}
+
+var aggregates = map[string]bool{"max": true, "min": true, "avg": true, "sum": true, "count": true}
+
+// ParseCondition parses a condition such as "max(samples.r) > cpus".
+func ParseCondition(s string) (*Condition, error) {
+	toks, err := tokenize(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %w", s, err)
+	}
+	c := &Condition{text: s}
+	var conj []comparison
+	for len(toks) > 0 {
+		var cmp comparison
+		if cmp, toks, err = parseComparison(toks); err != nil {
+			return nil, fmt.Errorf("invalid condition %q: %w", s, err)
+		}
+		conj = append(conj, cmp)
+		if len(toks) == 0 {
+			break
+		}
+		switch toks[0] {
+		case "and":
+		case "or":
+			c.or = append(c.or, conj)
+			conj = nil
+		default:
+			return nil, fmt.Errorf("invalid condition %q: expected and/or, got %q", s, toks[0])
+		}
+		toks = toks[1:]
+		if len(toks) == 0 {
+			return nil, fmt.Errorf("invalid condition %q: missing comparison after and/or", s)
+		}
+	}
+	if len(conj) == 0 {
+		return nil, fmt.Errorf("invalid condition %q: empty", s)
+	}
+	c.or = append(c.or, conj)
+	return c, nil
+}
+
+// String returns the condition as written.
+func (c *Condition) String() string {
+	return c.text
+}
+
+func tokenize(s string) ([]string, error) {
+	var toks []string
+	for i := 0; i < len(s); {
+		r := rune(s[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("()", r):
+			toks = append(toks, string(r))
+			i++
+		case strings.ContainsRune("<>=!", r):
+			j := i + 1
+			if j < len(s) && s[j] == '=' {
+				j++
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		case r == '_' || r == '.' || r == '-' || r == '%' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			j := i
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || s[j] == '-' || s[j] == '%' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q", r)
+		}
+	}
+	return toks, nil
+}
+
+func parseComparison(toks []string) (comparison, []string, error) {
+	var c comparison
+	var err error
+	if c.left, toks, err = parseOperand(toks); err != nil {
+		return c, nil, err
+	}
+	if len(toks) == 0 {
+		return c, nil, fmt.Errorf("missing comparison operator")
+	}
+	switch toks[0] {
+	case ">", ">=", "<", "<=", "==", "!=":
+		c.op = toks[0]
+	default:
+		return c, nil, fmt.Errorf("expected comparison operator, got %q", toks[0])
+	}
+	c.right, toks, err = parseOperand(toks[1:])
+	return c, toks, err
+}
+
+func parseOperand(toks []string) (operand, []string, error) {
+	if len(toks) == 0 {
+		return operand{}, nil, fmt.Errorf("missing operand")
+	}
+	t := toks[0]
+	if aggregates[t] && len(toks) > 1 && toks[1] == "(" {
+		if len(toks) < 4 || toks[3] != ")" || !isPath(toks[2]) {
+			return operand{}, nil, fmt.Errorf("expected %s(field)", t)
+		}
+		return operand{fn: t, path: strings.Split(toks[2], ".")}, toks[4:], nil
+	}
+	if f, err := strconv.ParseFloat(t, 64); err == nil {
+		return operand{num: f}, toks[1:], nil
+	}
+	if !isPath(t) {
+		return operand{}, nil, fmt.Errorf("unexpected %q", t)
+	}
+	return operand{path: strings.Split(t, ".")}, toks[1:], nil
+}
+
+func isPath(t string) bool {
+	if t == "" || t == "and" || t == "or" || strings.ContainsAny(t, "()<>=!") {
+		return false
+	}
+	for _, seg := range strings.Split(t, ".") {
+		if seg == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// checkFields rejects field paths that the result of the named parser does
+// not have, optionally prefixed with the parser name. A single name that is
+// not a field is left to resolve to a variable such as cpus. Conditions on
+// unknown parsers are not checked.
+func (c *Condition) checkFields(parserName string) error {
+	result, ok := parser.Result(parserName)
+	if !ok {
+		return nil
+	}
+	t := reflect.TypeOf(result)
+	for _, conj := range c.or {
+		for _, cmp := range conj {
+			for _, o := range []operand{cmp.left, cmp.right} {
+				path := o.path
+				switch {
+				case len(path) > 1 && path[0] == parserName:
+					path = path[1:]
+				case len(path) <= 1:
+					continue
+				}
+				if !hasField(t, path) {
+					return fmt.Errorf("unknown field %q in %s output", strings.Join(o.path, "."), parserName)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasField reports whether path names a field of the JSON encoding of t,
+// looking through pointers and lists as lookup does.
+func hasField(t reflect.Type, path []string) bool {
+	for len(path) > 0 {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array:
+			t = t.Elem()
+		case reflect.Struct:
+			f, ok := jsonField(t, path[0])
+			if !ok {
+				return false
+			}
+			t, path = f.Type, path[1:]
+		case reflect.Map, reflect.Interface:
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// jsonField returns the field of struct type t encoded under name.
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		if tag == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Eval evaluates the condition over data, a value as produced by Normalize,
+// with vars resolving single-segment names that data does not have. It
+// returns whether the condition holds and, if so, the two sides of the first
+// comparison that made it hold.
+func (c *Condition) Eval(data any, vars map[string]float64) (ok bool, value, threshold float64) {
+	for _, conj := range c.or {
+		all := true
+		var v, t float64
+		for i, cmp := range conj {
+			cv, ct, cok := cmp.eval(data, vars)
+			if !cok {
+				all = false
+				break
+			}
+			if i == 0 {
+				v, t = cv, ct
+			}
+		}
+		if all {
+			return true, v, t
+		}
+	}
+	return false, 0, 0
+}
+
+// HoldsForAll evaluates the condition as a requirement: a path over a list
+// satisfies a comparison only if every one of its values does. If the
+// condition does not hold, value is the worst value that violated the first
+// comparison, and known is false when that comparison had nothing to
+// compare.
+func (c *Condition) HoldsForAll(data any, vars map[string]float64) (ok bool, value float64, known bool) {
+	first := true
+	for _, conj := range c.or {
+		all := true
+		for _, cmp := range conj {
+			v, violated, k := cmp.violation(data, vars)
+			if !violated {
+				continue
+			}
+			if first {
+				value, known, first = v, k, false
+			}
+			all = false
+			break
+		}
+		if all {
+			return true, 0, false
+		}
+	}
+	return false, value, known
+}
+
+// violation reports whether some value on the left fails the comparison and
+// returns the one furthest from the threshold. A comparison with no values
+// on either side is violated without a known value.
+func (c comparison) violation(data any, vars map[string]float64) (value float64, violated, known bool) {
+	right := c.right.values(data, vars)
+	left := c.left.values(data, vars)
+	if len(right) == 0 || len(left) == 0 {
+		return 0, true, false
+	}
+	for _, v := range left {
+		if compare(v, c.op, right[0]) {
+			continue
+		}
+		if !violated || (strings.HasPrefix(c.op, ">") && v < value) || (strings.HasPrefix(c.op, "<") && v > value) {
+			value = v
+		}
+		violated = true
+	}
+	return value, violated, violated
+}
+
+func (c comparison) eval(data any, vars map[string]float64) (value, threshold float64, ok bool) {
+	right := c.right.values(data, vars)
+	if len(right) == 0 {
+		return 0, 0, false
+	}
+	threshold = right[0]
+	for _, v := range c.left.values(data, vars) {
+		if !compare(v, c.op, threshold) {
+			continue
+		}
+		// Report the most extreme value that satisfied the comparison
+		if !ok || (strings.HasPrefix(c.op, ">") && v > value) || (strings.HasPrefix(c.op, "<") && v < value) {
+			value = v
+		}
+		ok = true
+	}
+	return value, threshold, ok
+}
+
+func compare(a float64, op string, b float64) bool {
+	switch op {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case "==":
+		return a == b
+	default:
+		return a != b
+	}
+}
+
+func (o operand) values(data any, vars map[string]float64) []float64 {
+	if o.path == nil {
+		return []float64{o.num}
+	}
+	leaves, found := lookup(data, o.path)
+	if o.fn == "count" {
+		return []float64{float64(len(leaves))}
+	}
+	var vals []float64
+	for _, l := range leaves {
+		if v, ok := toNumber(l); ok {
+			vals = append(vals, v)
+		}
+	}
+	if !found && len(o.path) == 1 {
+		if v, ok := vars[o.path[0]]; ok {
+			vals = []float64{v}
+		}
+	}
+	if o.fn == "" || len(vals) == 0 {
+		return vals
+	}
+	agg := vals[0]
+	for _, v := range vals[1:] {
+		switch o.fn {
+		case "max":
+			agg = max(agg, v)
+		case "min":
+			agg = min(agg, v)
+		default:
+			agg += v
+		}
+	}
+	if o.fn == "avg" {
+		agg /= float64(len(vals))
+	}
+	return []float64{agg}
+}
+
+// lookup collects the values at path in data, descending into every element
+// of lists along the way. It reports whether the first path segment exists.
+func lookup(data any, path []string) ([]any, bool) {
+	switch d := data.(type) {
+	case []any:
+		var leaves []any
+		found := false
+		for _, e := range d {
+			l, ok := lookup(e, path)
+			leaves = append(leaves, l...)
+			found = found || ok
+		}
+		return leaves, found
+	case map[string]any:
+		if len(path) == 0 {
+			return []any{d}, true
+		}
+		v, ok := d[path[0]]
+		if !ok {
+			return nil, false
+		}
+		leaves, _ := lookup(v, path[1:])
+		return leaves, true
+	}
+	if len(path) > 0 {
+		return nil, false
+	}
+	return []any{data}, true
+}
+
+// toNumber converts a JSON scalar to a number.
+func toNumber(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Normalize converts a parser result into the generic maps and lists that
+// conditions are evaluated over. Field names are those of the result's JSON
+// encoding.
+func Normalize(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConditionErrors(t *testing.T) {
+	tests := []struct {
+		cond string
+		want string
+	}{
+		{"", "empty"},
+		{"load1 > 1 and", "missing comparison after and/or"},
+		{"load1 > 1 or", "missing comparison after and/or"},
+		{"load1", "missing comparison operator"},
+		{"load1 1", "expected comparison operator"},
+		{"load1 >", "missing operand"},
+		{"max( > 1", "expected max(field)"},
+		{"max() > 1", "expected max(field)"},
+		{"count(samples.r > 1", "expected count(field)"},
+		{"load1 > 1 load5 > 1", "expected and/or"},
+		{"load1 > 1 & load5 > 1", `unexpected '&'`},
+		{"load1 > $cpus", `unexpected '$'`},
+		{"samples..r > 1", `unexpected "samples..r"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cond, func(t *testing.T) {
+			_, err := ParseCondition(tt.cond)
+			if err == nil {
+				t.Fatalf("ParseCondition(%q) succeeded", tt.cond)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseCondition(%q) = %v; want %s", tt.cond, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionEval(t *testing.T) {
+	samples := map[string]any{"samples": []any{
+		map[string]any{"r": 1.0, "id": 90.0},
+		map[string]any{"r": 6.0, "id": 40.0},
+		map[string]any{"r": 3.0, "id": 70.0},
+	}}
+	tests := []struct {
+		name      string
+		cond      string
+		data      any
+		vars      map[string]float64
+		ok        bool
+		value     float64
+		threshold float64
+	}{
+		{"any element", "samples.r > 5", samples, nil, true, 6, 5},
+		{"most extreme value", "samples.r > 0", samples, nil, true, 6, 0},
+		{"lower bound", "samples.id < 80", samples, nil, true, 40, 80},
+		{"no element", "samples.r > 6", samples, nil, false, 0, 0},
+		{"max", "max(samples.r) >= 6", samples, nil, true, 6, 6},
+		{"min", "min(samples.id) == 40", samples, nil, true, 40, 40},
+		{"avg", "avg(samples.id) > 66", samples, nil, true, 200.0 / 3, 66},
+		{"sum", "sum(samples.r) == 10", samples, nil, true, 10, 10},
+		{"count", "count(samples.r) == 3", samples, nil, true, 3, 3},
+		{"count over an empty list", "count(samples.r) == 0", map[string]any{"samples": []any{}}, nil, true, 0, 0},
+		{"max over an empty list", "max(samples.r) >= 0", map[string]any{"samples": []any{}}, nil, false, 0, 0},
+		{"cpus from vars", "load1 > cpus", map[string]any{"load1": 5.0}, map[string]float64{"cpus": 4}, true, 5, 4},
+		{"field wins over var", "load1 > cpus", map[string]any{"load1": 5.0, "cpus": 8.0}, map[string]float64{"cpus": 4}, false, 0, 0},
+		{"missing var", "load1 > cpus", map[string]any{"load1": 5.0}, nil, false, 0, 0},
+		{"missing field", "load5 > 1", map[string]any{"load1": 5.0}, nil, false, 0, 0},
+		{"number on the left", "4 < load1", map[string]any{"load1": 5.0}, nil, true, 4, 5},
+		// "and" binds tighter than "or": a or (b and c)
+		{"or then and, first holds", "a > 0 or b > 0 and c > 0", map[string]any{"a": 1.0, "b": 0.0, "c": 0.0}, nil, true, 1, 0},
+		{"or then and, second fails", "a > 0 or b > 0 and c > 0", map[string]any{"a": 0.0, "b": 1.0, "c": 0.0}, nil, false, 0, 0},
+		{"or then and, second holds", "a > 0 or b > 0 and c > 0", map[string]any{"a": 0.0, "b": 1.0, "c": 2.0}, nil, true, 1, 0},
+		{"and then or", "a > 0 and b > 0 or c > 0", map[string]any{"a": 0.0, "b": 0.0, "c": 2.0}, nil, true, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCondition(tt.cond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ok, value, threshold := c.Eval(tt.data, tt.vars)
+			if ok != tt.ok || value != tt.value || threshold != tt.threshold {
+				t.Errorf("Eval(%q) = %v, %v, %v; want %v, %v, %v", tt.cond, ok, value, threshold, tt.ok, tt.value, tt.threshold)
+			}
+		})
+	}
+}
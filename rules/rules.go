@@ -0,0 +1,284 @@
+// Package rules derives findings from command output with deterministic
+// heuristics, so that a run is interpreted even without an LLM.
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gradient-engineer/playbook"
+)
+
+// Severities of a finding, from least to most severe.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+	SeverityOff      = "off" // Disables a rule
+)
+
+// Input is the outcome of one command as seen by the rules.
+type Input struct {
+	Command string // Playbook command line
+	Program string // Name of the program the command runs
+	Parser  string // Parser applied to stdout; empty for none
+	Parsed  any    // Parser result; nil if there is none
+	Output  string // Full stdout followed by stderr
+}
+
+// Finding is a rule that fired for a command.
+type Finding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Command  string `json:"command"`
+}
+
+// Builtin are the checks from Brendan Gregg's "Linux Performance Analysis in
+// 60,000 Milliseconds", keyed to the parsers of the bundled playbooks.
+var Builtin = []playbook.Rule{
+	{
+		ID:       "load-above-cpus",
+		Parser:   "uptime",
+		When:     "load1 > cpus",
+		Severity: SeverityWarning,
+		Message:  "1-minute load average {value} exceeds the {cpus} CPUs: tasks are queuing for CPU or blocked on I/O",
+	},
+	{
+		ID:       "run-queue-saturated",
+		Parser:   "vmstat",
+		When:     "samples.r > cpus",
+		Severity: SeverityWarning,
+		Message:  "vmstat saw {value} runnable tasks on {cpus} CPUs: the CPUs are saturated",
+	},
+	{
+		ID:       "swapping",
+		Parser:   "vmstat",
+		When:     "samples.si > 0 or samples.so > 0",
+		Severity: SeverityWarning,
+		Message:  "The system is swapping (up to {value} per second in vmstat si/so): memory is short",
+	},
+	{
+		ID:       "high-iowait",
+		Parser:   "mpstat",
+		When:     "samples.iowait > 20",
+		Severity: SeverityWarning,
+		Message:  "A CPU spent {value}% of an interval waiting on I/O",
+	},
+	{
+		ID:       "disk-saturated",
+		Parser:   "iostat",
+		When:     "reports.devices.util > 90",
+		Severity: SeverityWarning,
+		Message:  "A disk was busy {value}% of the time and is likely saturated",
+	},
+	{
+		ID:       "disk-latency",
+		Parser:   "iostat",
+		When:     "reports.devices.await > 50",
+		Severity: SeverityWarning,
+		Message:  "Average I/O latency reached {value} ms on a disk",
+	},
+	{
+		ID:       "tcp-retransmits",
+		Parser:   "sar-tcp",
+		When:     "samples.retrans_per_s > 10",
+		Severity: SeverityWarning,
+		Message:  "TCP retransmits reached {value}/s: look for packet loss or an overloaded peer",
+	},
+	{
+		ID:       "oom-killer",
+		Command:  "dmesg",
+		Match:    `(?i)out of memory|oom-kill|invoked oom-killer`,
+		Severity: SeverityCritical,
+		Message:  "The kernel OOM killer ran: {match}",
+	},
+}
+
+// Engine evaluates a fixed set of rules.
+type Engine struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	playbook.Rule
+	cond *Condition
+	re   *regexp.Regexp
+}
+
+// New compiles the built-in rules together with custom ones. A custom rule
+// replaces the built-in rule with the same ID, and rules with severity off
+// are dropped.
+func New(custom []playbook.Rule) (*Engine, error) {
+	byID := map[string]int{}
+	var all []playbook.Rule
+	for _, r := range append(append([]playbook.Rule{}, Builtin...), custom...) {
+		if i, ok := byID[r.ID]; ok && r.ID != "" {
+			all[i] = r
+			continue
+		}
+		byID[r.ID] = len(all)
+		all = append(all, r)
+	}
+
+	e := &Engine{}
+	for _, r := range all {
+		if r.Severity == SeverityOff {
+			continue
+		}
+		c, err := compile(r)
+		if err != nil {
+			return nil, err
+		}
+		e.rules = append(e.rules, c)
+	}
+	return e, nil
+}
+
+func compile(r playbook.Rule) (compiledRule, error) {
+	c := compiledRule{Rule: r}
+	if r.ID == "" {
+		return c, fmt.Errorf("rule without id")
+	}
+	switch r.Severity {
+	case "":
+		c.Severity = SeverityWarning
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return c, fmt.Errorf("rule %s: unknown severity %q (want info, warning, critical or off)", r.ID, r.Severity)
+	}
+	if r.Parser == "" && r.Command == "" {
+		return c, fmt.Errorf("rule %s: needs parser or command", r.ID)
+	}
+	if (r.When == "") == (r.Match == "") {
+		return c, fmt.Errorf("rule %s: needs exactly one of when or match", r.ID)
+	}
+	if r.When != "" && r.Parser == "" {
+		return c, fmt.Errorf("rule %s: when needs a parser", r.ID)
+	}
+	var err error
+	if r.When != "" {
+		if c.cond, err = ParseCondition(r.When); err != nil {
+			return c, fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+		if err := c.cond.checkFields(r.Parser); err != nil {
+			return c, fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+	}
+	if r.Match != "" {
+		if c.re, err = regexp.Compile(r.Match); err != nil {
+			return c, fmt.Errorf("rule %s: invalid match: %w", r.ID, err)
+		}
+	}
+	return c, nil
+}
+
+// Evaluate runs every rule against the inputs it applies to. vars provides
+// values such as the CPU count to conditions and messages. Findings are
+// ordered by severity, most severe first, then by rule.
+func (e *Engine) Evaluate(inputs []Input, vars map[string]float64) []Finding {
+	var findings []Finding
+	for _, r := range e.rules {
+		for _, in := range inputs {
+			if !r.appliesTo(in) {
+				continue
+			}
+			placeholders := map[string]string{}
+			switch {
+			case r.cond != nil:
+				ok, value, threshold := r.cond.Eval(Normalize(in.Parsed), vars)
+				if !ok {
+					continue
+				}
+				placeholders["value"] = formatNumber(value)
+				placeholders["threshold"] = formatNumber(threshold)
+			default:
+				loc := r.re.FindStringIndex(in.Output)
+				if loc == nil {
+					continue
+				}
+				placeholders["match"] = matchedLine(in.Output, loc)
+			}
+			findings = append(findings, Finding{
+				Rule:     r.ID,
+				Severity: r.Severity,
+				Message:  expand(r.Message, placeholders, vars),
+				Command:  in.Command,
+			})
+		}
+	}
+	Sort(findings)
+	return findings
+}
+
+// Sort orders findings by severity, most severe first, keeping the order of
+// findings with the same severity.
+func Sort(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRank(findings[i].Severity) > severityRank(findings[j].Severity)
+	})
+}
+
+// Worst returns the highest severity among findings, or "" if there are none.
+func Worst(findings []Finding) string {
+	worst := ""
+	for _, f := range findings {
+		if worst == "" || severityRank(f.Severity) > severityRank(worst) {
+			worst = f.Severity
+		}
+	}
+	return worst
+}
+
+func (r compiledRule) appliesTo(in Input) bool {
+	if r.Parser != "" && (r.Parser != in.Parser || in.Parsed == nil) {
+		return false
+	}
+	if r.Command != "" && r.Command != in.Program && r.Command != in.Command {
+		return false
+	}
+	return true
+}
+
+func severityRank(s string) int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// matchedLine returns the line of output containing the match at loc.
+func matchedLine(output string, loc []int) string {
+	start := strings.LastIndexByte(output[:loc[0]], '\n') + 1
+	end := len(output)
+	if i := strings.IndexByte(output[loc[1]:], '\n'); i >= 0 {
+		end = loc[1] + i
+	}
+	return strings.TrimSpace(output[start:end])
+}
+
+// expand replaces {name} in msg with placeholders and vars.
+func expand(msg string, placeholders map[string]string, vars map[string]float64) string {
+	var pairs []string
+	for k, v := range placeholders {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	for k, v := range vars {
+		pairs = append(pairs, "{"+k+"}", formatNumber(v))
+	}
+	return strings.NewReplacer(pairs...).Replace(msg)
+}
+
+// formatNumber prints v with at most two decimals.
+func formatNumber(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
@@ -0,0 +1,169 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"gradient-engineer/parser"
+	"gradient-engineer/playbook"
+)
+
+// TestBuiltinSkipsSinceBoot feeds output whose since-boot line or report
+// would trip a rule, followed by quiet intervals.
+func TestBuiltinSkipsSinceBoot(t *testing.T) {
+	vmstat := `procs -----------memory---------- ---swap-- -----io---- -system-- ------cpu-----
+ r  b   swpd   free   buff  cache   si   so    bi    bo   in   cs us sy id wa st
+ 9  0  10240 512044  20480 901232    3    5    30    40  150  300  5  2 92  1  0
+ 1  0  10240 512044  20480 901232    0    0     0     0  150  300  5  2 92  1  0
+`
+	iostat := `avg-cpu:  %user   %nice %system %iowait  %steal   %idle
+           5.20    0.00    1.80    3.10    0.40   89.50
+
+Device            r/s     w/s     rkB/s     wkB/s r_await w_await  aqu-sz  %util
+sda            100.00  100.00   4000.00   4000.00  80.00   90.00    9.00  99.00
+
+avg-cpu:  %user   %nice %system %iowait  %steal   %idle
+           1.00    0.00    1.00    0.00    0.00   98.00
+
+Device            r/s     w/s     rkB/s     wkB/s r_await w_await  aqu-sz  %util
+sda              1.00    1.00      4.00      4.00   1.00    1.00    0.01   0.20
+`
+	tests := []struct {
+		parser string
+		output string
+		fired  []string // Rules expected once the quiet interval is replaced
+	}{
+		{"vmstat", vmstat, []string{"run-queue-saturated", "swapping"}},
+		{"iostat", iostat, []string{"disk-saturated", "disk-latency"}},
+	}
+	e, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]float64{"cpus": 4}
+	for _, tt := range tests {
+		t.Run(tt.parser, func(t *testing.T) {
+			parsed, err := parser.Parse(tt.parser, tt.output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f := e.Evaluate([]Input{{Command: tt.parser, Parser: tt.parser, Parsed: parsed}}, vars); len(f) != 0 {
+				t.Errorf("since-boot data fired %v", f)
+			}
+
+			// The same values in an interval do fire
+			switch p := parsed.(type) {
+			case *parser.Vmstat:
+				p.Samples[0] = *p.SinceBoot
+			case *parser.Iostat:
+				p.Reports[0] = *p.SinceBoot
+			}
+			got := map[string]bool{}
+			for _, f := range e.Evaluate([]Input{{Command: tt.parser, Parser: tt.parser, Parsed: parsed}}, vars) {
+				got[f.Rule] = true
+			}
+			for _, id := range tt.fired {
+				if !got[id] {
+					t.Errorf("%s did not fire on an interval; got %v", id, got)
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	uptime := &parser.Uptime{Load1: 6.5, Load5: 2, Load15: 1}
+	dmesg := "[ 10.0] eth0: link up\n[ 42.1] Out of memory: Killed process 4242 (java)\n[ 43.0] done\n"
+	tests := []struct {
+		name   string
+		custom []playbook.Rule
+		input  Input
+		want   []Finding
+	}{
+		{
+			name:  "condition with cpus",
+			input: Input{Command: "uptime", Parser: "uptime", Parsed: uptime},
+			want:  []Finding{{Rule: "load-above-cpus", Severity: SeverityWarning, Message: "1-minute load average 6.5 exceeds the 4 CPUs: tasks are queuing for CPU or blocked on I/O", Command: "uptime"}},
+		},
+		{
+			name:  "match",
+			input: Input{Command: "dmesg -T", Program: "dmesg", Output: dmesg},
+			want:  []Finding{{Rule: "oom-killer", Severity: SeverityCritical, Message: "The kernel OOM killer ran: [ 42.1] Out of memory: Killed process 4242 (java)", Command: "dmesg -T"}},
+		},
+		{
+			name:  "match on another program",
+			input: Input{Command: "journalctl -k", Program: "journalctl", Output: dmesg},
+		},
+		{
+			name:  "parser rule without parsed output",
+			input: Input{Command: "uptime", Parser: "uptime"},
+		},
+		{
+			name:   "override",
+			custom: []playbook.Rule{{ID: "load-above-cpus", Parser: "uptime", When: "load1 > 10", Severity: SeverityCritical}},
+			input:  Input{Command: "uptime", Parser: "uptime", Parsed: uptime},
+		},
+		{
+			name:   "override fires",
+			custom: []playbook.Rule{{ID: "load-above-cpus", Parser: "uptime", When: "load5 >= 2", Severity: SeverityInfo, Message: "load5 is {value}"}},
+			input:  Input{Command: "uptime", Parser: "uptime", Parsed: uptime},
+			want:   []Finding{{Rule: "load-above-cpus", Severity: SeverityInfo, Message: "load5 is 2", Command: "uptime"}},
+		},
+		{
+			name:   "severity off",
+			custom: []playbook.Rule{{ID: "oom-killer", Severity: SeverityOff}},
+			input:  Input{Command: "dmesg", Program: "dmesg", Output: dmesg},
+		},
+		{
+			name:   "custom rule",
+			custom: []playbook.Rule{{ID: "link-up", Command: "dmesg", Match: `link up`, Message: "{match}"}},
+			input:  Input{Command: "dmesg", Program: "dmesg", Output: dmesg},
+			want: []Finding{
+				{Rule: "oom-killer", Severity: SeverityCritical, Message: "The kernel OOM killer ran: [ 42.1] Out of memory: Killed process 4242 (java)", Command: "dmesg"},
+				{Rule: "link-up", Severity: SeverityWarning, Message: "[ 10.0] eth0: link up", Command: "dmesg"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(tt.custom)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := e.Evaluate([]Input{tt.input}, map[string]float64{"cpus": 4})
+			if len(got) != len(tt.want) {
+				t.Fatalf("Evaluate = %+v; want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("finding %d = %+v; want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule playbook.Rule
+		want string
+	}{
+		{"no id", playbook.Rule{Parser: "uptime", When: "load1 > 1"}, "rule without id"},
+		{"unknown severity", playbook.Rule{ID: "x", Parser: "uptime", When: "load1 > 1", Severity: "fatal"}, `unknown severity "fatal"`},
+		{"no input", playbook.Rule{ID: "x", When: "load1 > 1"}, "needs parser or command"},
+		{"when and match", playbook.Rule{ID: "x", Parser: "uptime", When: "load1 > 1", Match: "x"}, "exactly one of when or match"},
+		{"when without parser", playbook.Rule{ID: "x", Command: "uptime", When: "load1 > 1"}, "when needs a parser"},
+		{"bad condition", playbook.Rule{ID: "x", Parser: "uptime", When: "load1 >"}, "rule x: invalid condition"},
+		{"unknown field", playbook.Rule{ID: "x", Parser: "uptime", When: "load_1 > 1 and uptime.load > 1"}, `rule x: unknown field "uptime.load"`},
+		{"bad match", playbook.Rule{ID: "x", Command: "dmesg", Match: "("}, "rule x: invalid match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New([]playbook.Rule{tt.rule})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("New = %v; want %s", err, tt.want)
+			}
+		})
+	}
+}
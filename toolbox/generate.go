@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,8 +12,12 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"time"
 
+	"gradient-engineer/binindex"
+	"gradient-engineer/manifest"
 	"gradient-engineer/playbook"
 
 	"github.com/spf13/cobra"
@@ -18,8 +25,9 @@ import (
 )
 
 var (
-	playbookPath string
-	outDir       string
+	playbookPath   string
+	outDir         string
+	signingKeyPath string
 )
 
 func main() {
@@ -41,13 +49,32 @@ target systems.`,
 		},
 	}
 
+	var keygenCmd = &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate an ed25519 key pair for signing toolbox manifests",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pub, priv, err := ed25519.GenerateKey(nil)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("public key:  %s\n", base64.StdEncoding.EncodeToString(pub))
+			fmt.Printf("private key: %s\n", base64.StdEncoding.EncodeToString(priv.Seed()))
+			return nil
+		},
+	}
+
 	// Define flags
 	rootCmd.Flags().StringVarP(&playbookPath, "playbook", "p", "", "Path to playbook file (required)")
 	rootCmd.Flags().StringVarP(&outDir, "out", "o", ".", "Output directory for generated archive")
+	rootCmd.Flags().StringVar(&signingKeyPath, "signing-key", "",
+		"Path to a base64 ed25519 private key used to sign the manifest (or set TOOLBOX_SIGNING_KEY)")
 
 	// Mark required flags
 	rootCmd.MarkFlagRequired("playbook")
 
+	rootCmd.AddCommand(keygenCmd)
+
 	// Execute the command
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -77,13 +104,18 @@ func generateToolbox() error {
 	toolboxDir, _ := filepath.Abs(filepath.Join(workDir, "toolbox"))
 
 	if runtime.GOOS == "linux" {
-		if err := nixCopy(toolboxDir, cfg.Nixpkgs.Version, cfg.Nixpkgs.Packages); err != nil {
+		packages := toolboxPackages(cfg)
+		if err := nixCopy(toolboxDir, cfg.Nixpkgs.Version, packages); err != nil {
 			return fmt.Errorf("nix copy failed: %w", err)
 		}
 
 		if err := fetchAndInstallProot(toolboxDir); err != nil {
 			return fmt.Errorf("failed to install proot: %w", err)
 		}
+
+		if err := writeBinIndex(toolboxDir, cfg.Nixpkgs.Version, packages); err != nil {
+			return fmt.Errorf("failed to write binary index: %w", err)
+		}
 	}
 
 	// Include the playbook file inside the toolbox directory
@@ -102,9 +134,115 @@ func generateToolbox() error {
 	}
 
 	fmt.Printf("created %s\n", outPath)
+
+	if err := writeManifest(cfg, outPath, toolboxDir); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// writeManifest records the digest of the archive and of every file in the
+// toolbox next to the archive, and signs it when a signing key is available.
+func writeManifest(cfg *playbook.PlaybookConfig, archivePath, toolboxDir string) error {
+	archiveSum, err := manifest.HashFile(archivePath)
+	if err != nil {
+		return err
+	}
+	m := manifest.Manifest{
+		ID:            cfg.ID,
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		Archive:       filepath.Base(archivePath),
+		ArchiveSHA256: archiveSum,
+		Files:         map[string]string{},
+	}
+
+	// Entry names mirror the tar layout, which is rooted at the toolbox directory's parent
+	parent := filepath.Dir(toolboxDir)
+	err = filepath.WalkDir(toolboxDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(parent, p)
+		if err != nil {
+			return err
+		}
+		sum, err := manifest.HashFile(p)
+		if err != nil {
+			return err
+		}
+		m.Files[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifestPath := archivePath + manifest.Suffix
+	if err := os.WriteFile(manifestPath, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("created %s\n", manifestPath)
+
+	key, err := loadSigningKey()
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		fmt.Println("no signing key provided; manifest is unsigned")
+		return nil
+	}
+	sigPath := manifestPath + manifest.SignatureSuffix
+	if err := os.WriteFile(sigPath, manifest.Sign(data, key), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("created %s\n", sigPath)
 	return nil
 }
 
+// loadSigningKey reads the manifest signing key from --signing-key or the
+// TOOLBOX_SIGNING_KEY environment variable. It returns nil when neither is set.
+func loadSigningKey() (ed25519.PrivateKey, error) {
+	encoded := os.Getenv("TOOLBOX_SIGNING_KEY")
+	if signingKeyPath != "" {
+		data, err := os.ReadFile(signingKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key: %w", err)
+		}
+		encoded = string(data)
+	}
+	if encoded == "" {
+		return nil, nil
+	}
+	return manifest.ParsePrivateKey(encoded)
+}
+
+// shellPackages provide the sh that shell: true commands run through.
+var shellPackages = []string{"bash", "bashInteractive", "busybox"}
+
+// toolboxPackages returns the packages to bundle: those the playbook lists,
+// plus bash if a command needs a shell and none of shellPackages is listed.
+func toolboxPackages(cfg *playbook.PlaybookConfig) []string {
+	pkgs := cfg.Nixpkgs.Packages
+	if !slices.ContainsFunc(cfg.Commands, func(c playbook.PlaybookCommand) bool { return c.Shell }) {
+		return pkgs
+	}
+	for _, p := range pkgs {
+		if slices.Contains(shellPackages, p) {
+			return pkgs
+		}
+	}
+	fmt.Println("adding bash for shell commands")
+	return append(slices.Clone(pkgs), "bash")
+}
+
 func readPlaybook(path string) (*playbook.PlaybookConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -127,15 +265,9 @@ func nixCopy(destDir string, version string, pkgs []string) error {
 		"copy",
 		"--to", destDir,
 	}
-	// Build flake reference. If a version (commit SHA) is provided, pin to that revision.
-	// Otherwise, fall back to the registry alias "nixpkgs".
-	flakeRef := "nixpkgs"
-	if version != "" {
-		// Expecting a commit SHA; use the GitHub flake URL form.
-		flakeRef = "github:NixOS/nixpkgs/" + version
-	}
+	ref := flakeRef(version)
 	for _, p := range pkgs {
-		args = append(args, flakeRef+"#"+p)
+		args = append(args, ref+"#"+p)
 	}
 	cmd := exec.Command("nix", args...)
 	cmd.Stdout = os.Stdout
@@ -143,6 +275,42 @@ func nixCopy(destDir string, version string, pkgs []string) error {
 	return cmd.Run()
 }
 
+// flakeRef builds the nixpkgs flake reference. If a version (commit SHA) is
+// provided, pin to that revision. Otherwise, fall back to the registry alias
+// "nixpkgs".
+func flakeRef(version string) string {
+	if version == "" {
+		return "nixpkgs"
+	}
+	// Expecting a commit SHA; use the GitHub flake URL form.
+	return "github:NixOS/nixpkgs/" + version
+}
+
+// writeBinIndex records which store path provides every binary in the
+// toolbox, so the app neither scans the store nor picks an arbitrary copy
+// when two packages ship the same name.
+func writeBinIndex(toolboxDir, version string, pkgs []string) error {
+	ref := flakeRef(version)
+	owners := map[string]string{}
+	for _, p := range pkgs {
+		out, err := exec.Command("nix",
+			"--extra-experimental-features", "flakes",
+			"--extra-experimental-features", "nix-command",
+			"path-info", ref+"#"+p).Output()
+		if err != nil {
+			return fmt.Errorf("nix path-info %s: %w", p, err)
+		}
+		for _, storePath := range strings.Fields(string(out)) {
+			owners[filepath.Base(storePath)] = p
+		}
+	}
+	idx, err := binindex.Build(filepath.Join(toolboxDir, "nix", "store"), owners, pkgs)
+	if err != nil {
+		return err
+	}
+	return idx.Write(filepath.Join(toolboxDir, binindex.FileName))
+}
+
 func fetchAndInstallProot(destDir string) error {
 	arch := runtime.GOARCH
 	var url string